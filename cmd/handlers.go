@@ -4,14 +4,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"net/http"
+	"runtime/debug"
 
+	"github.com/grupozap/aegir/internal/pkg/audit"
+	"github.com/grupozap/aegir/internal/pkg/messages"
 	notifications "github.com/grupozap/aegir/internal/pkg/notifications/slack"
 	"github.com/grupozap/aegir/internal/pkg/rules"
 	"github.com/grupozap/aegir/internal/pkg/utils"
@@ -31,6 +33,25 @@ var slackToken string
 var listenPort string
 var tlsCertPath string
 var tlsKeyPath string
+var clusterName string
+var notificationOpts = notifications.DefaultOptions()
+var notificationDrainTimeout time.Duration
+var notifier *notifications.Notifier
+var readTimeout time.Duration
+var writeTimeout time.Duration
+var idleTimeout time.Duration
+var shutdownDrainPeriod time.Duration
+var shutdownTimeout time.Duration
+var tlsReloadInterval time.Duration
+var selfProvision bool
+var kubeconfig string
+var serviceName string
+var serviceNamespace string
+var tlsSecretName string
+var webhookConfigName string
+var auditLogFile string
+var auditLog *audit.Logger
+var dryRunDiagnostics bool
 
 var serverCmd = &cobra.Command{
 	Use:   "server",
@@ -43,9 +64,32 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	serverCmd.PersistentFlags().StringVar(&tlsCertPath, "tls-cert-file", "", "Path to TLS certificate file")
 	serverCmd.PersistentFlags().StringVar(&tlsKeyPath, "tls-key-file", "", "Path to TLS key file")
+	serverCmd.PersistentFlags().DurationVar(&tlsReloadInterval, "tls-reload-interval", 30*time.Second, "How often the TLS certificate and key files, or the Secret of --self-provision, are checked for changes.")
+	serverCmd.PersistentFlags().BoolVar(&selfProvision, "self-provision", false, "Generate the TLS certificate, store it in a Secret and register the ValidatingWebhookConfiguration through the Kubernetes API.")
+	serverCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig file used by --self-provision, the in-cluster configuration is used when empty.")
+	serverCmd.PersistentFlags().StringVar(&serviceName, "service-name", "aegir", "Name of the Service exposing Aegir, used by --self-provision.")
+	serverCmd.PersistentFlags().StringVar(&serviceNamespace, "service-namespace", utils.GetEnv("POD_NAMESPACE", "default"), "Namespace of the Service exposing Aegir, used by --self-provision.")
+	serverCmd.PersistentFlags().StringVar(&tlsSecretName, "tls-secret-name", "aegir-tls", "Secret storing the TLS certificate generated by --self-provision.")
+	serverCmd.PersistentFlags().StringVar(&webhookConfigName, "webhook-config-name", "aegir-webhook", "ValidatingWebhookConfiguration managed by --self-provision.")
+	serverCmd.PersistentFlags().StringSliceVar(&discoveryFiles, "discovery-file", nil, "APIResourceList files describing kinds that are not built-in, like CRDs.")
+	serverCmd.PersistentFlags().StringSliceVar(&openAPIFiles, "openapi-file", nil, "OpenAPI v2 or v3 documents used to check that fields exist, instead of the bundled schemas.")
+	serverCmd.PersistentFlags().BoolVar(&strictRules, "strict-rules", false, "Refuse to start when a rule can never fire, like a field that does not exist in its kind.")
 	serverCmd.PersistentFlags().StringVar(&rulesFile, "rules-file", "", "File that contains the rules that will be applied for the Kubernetes resources.")
+	serverCmd.PersistentFlags().StringVar(&exemptionsFile, "exemptions-file", "", "File that contains exemptions waiving rules, in addition to the ones of the rules file.")
+	serverCmd.PersistentFlags().StringVar(&auditLogFile, "audit-log-file", "", "File the audit log is appended to, like the use of exemptions. It is written to the standard error when empty.")
+	serverCmd.PersistentFlags().BoolVar(&dryRunDiagnostics, "dry-run-diagnostics", false, "Describe the path, value and errors of every violation in the response to dry-run requests.")
 	serverCmd.PersistentFlags().StringVar(&slackToken, "slack-token", "", "Slack API Token to enable Aegir notifications")
 	serverCmd.PersistentFlags().StringVar(&listenPort, "port", "8443", "TCP port that connections will be listen.")
+	serverCmd.PersistentFlags().StringVar(&clusterName, "cluster-name", "", "Name of the cluster displayed in messages and notifications.")
+	serverCmd.PersistentFlags().IntVar(&notificationOpts.Workers, "notification-workers", notificationOpts.Workers, "Number of workers delivering Slack notifications.")
+	serverCmd.PersistentFlags().IntVar(&notificationOpts.QueueSize, "notification-queue-size", notificationOpts.QueueSize, "Maximum number of pending Slack notifications, new ones are dropped when it is full.")
+	serverCmd.PersistentFlags().IntVar(&notificationOpts.MaxRetries, "notification-max-retries", notificationOpts.MaxRetries, "Maximum number of retries for a failed Slack notification.")
+	serverCmd.PersistentFlags().DurationVar(&notificationDrainTimeout, "notification-drain-timeout", 10*time.Second, "Time to wait for pending Slack notifications on shutdown.")
+	serverCmd.PersistentFlags().DurationVar(&readTimeout, "read-timeout", 10*time.Second, "Maximum duration for reading an entire request.")
+	serverCmd.PersistentFlags().DurationVar(&writeTimeout, "write-timeout", 10*time.Second, "Maximum duration before timing out writes of a response.")
+	serverCmd.PersistentFlags().DurationVar(&idleTimeout, "idle-timeout", 60*time.Second, "Maximum amount of time to wait for the next request on keep-alive connections.")
+	serverCmd.PersistentFlags().DurationVar(&shutdownDrainPeriod, "shutdown-drain-period", 5*time.Second, "Time Aegir keeps serving requests as not ready after receiving SIGTERM, so it is removed from the Service endpoints.")
+	serverCmd.PersistentFlags().DurationVar(&shutdownTimeout, "shutdown-timeout", 20*time.Second, "Time to wait for in-flight requests to finish on shutdown.")
 }
 
 func initConfig() {
@@ -64,30 +108,170 @@ type validationFunc func(*v1beta1.AdmissionRequest) []*utils.Violation
 func validateRules(req *v1beta1.AdmissionRequest) []*utils.Violation {
 	raw := req.Object.Raw
 	rsc := rules.Resource{}
-	json.Unmarshal(raw, &rsc)
+	var decodeErr error
+	//Objects of DELETE requests are empty
+	if len(raw) > 0 {
+		decodeErr = json.Unmarshal(raw, &rsc)
+	}
+	data := messageData(req, &rsc)
+	in := ruleInput(req)
 	var violationsSlice []*utils.Violation
 	for _, rule := range rules.GetRules(req.Namespace, req.Kind.Kind) {
 		//Skip rule if namespace is inside SKIP_NAMESPACES environment variable
 		if rule.Namespace == "*" && utils.Include(skippedNamespaces, req.Namespace) {
 			continue
 		}
+		if !rule.AppliesTo(req.UserInfo.Username, req.UserInfo.Groups) || !rule.AppliesToOperation(string(req.Operation)) {
+			continue
+		}
+		var ruleViolations []*utils.Violation
 		for _, ruledef := range rule.RulesDefinitions {
-			violations := ruledef.GetViolations(string(raw))
+			var violations []*utils.Violation
+			if decodeErr != nil {
+				violations = []*utils.Violation{ruledef.EvaluationError("could not decode the object: %v", decodeErr)}
+			} else {
+				violations = ruledef.Evaluate(in)
+			}
 			for _, violated := range violations {
 				violated.SlackChannel = rule.SlackNotificationChannel
 				violated.RuleName = rule.Name
-				violationsSlice = append(violationsSlice, violated)
+				violated.EnforcementMode = rule.EnforcementMode
+				violated.RemediationURL = rule.RemediationURL
+				if violated.EvaluationError {
+					applyFailurePolicy(req, rule, violated, data)
+				}
+				renderViolation(rule, violated, data)
+				ruleViolations = append(ruleViolations, violated)
 			}
 		}
+		if len(ruleViolations) > 0 && exempted(req, rule, data, len(ruleViolations)) {
+			continue
+		}
+		violationsSlice = append(violationsSlice, ruleViolations...)
 	}
 	return violationsSlice
 }
 
+//applyFailurePolicy logs a violation reporting that rule could not be evaluated and, when the failure policy of the rule
+//is allow, turns it into a warning recorded in the audit log. Rules in warn mode never deny requests, whatever their failure policy.
+func applyFailurePolicy(req *v1beta1.AdmissionRequest, rule *rules.Rule, v *utils.Violation, data messages.Data) {
+	log.Printf("Rule %s could not be evaluated on %s %s/%s, applying failure_policy %s: %s", rule.Name, data.Kind, data.Namespace, data.Name, rule.FailurePolicy, v.Message)
+	if rule.FailurePolicy != rules.FailurePolicyAllow {
+		return
+	}
+	v.EnforcementMode = rules.EnforcementWarn
+	auditLog.Log(audit.Entry{
+		Event:     audit.EventFailureAllowed,
+		Rule:      rule.Name,
+		UID:       string(req.UID),
+		Kind:      data.Kind,
+		Namespace: data.Namespace,
+		Name:      data.Name,
+		User:      data.User,
+		Operation: data.Operation,
+		Error:     v.Message,
+		DryRun:    isDryRun(req),
+	})
+}
+
+//exempted reports whether an exemption waives the violations of rule, recording its use in the audit log.
+//Expired exemptions that would have waived them are logged and recorded as well.
+func exempted(req *v1beta1.AdmissionRequest, rule *rules.Rule, data messages.Data, violations int) bool {
+	exemption, expired := rules.FindExemption(rule.Name, req.Namespace, data.Name, time.Now())
+	entry := audit.Entry{
+		Rule:       rule.Name,
+		UID:        string(req.UID),
+		Kind:       data.Kind,
+		Namespace:  data.Namespace,
+		Name:       data.Name,
+		User:       data.User,
+		Operation:  data.Operation,
+		Violations: violations,
+		DryRun:     isDryRun(req),
+	}
+	for _, e := range expired {
+		log.Printf("Ignoring %s on %s %s/%s, it expired at %s", e, data.Kind, data.Namespace, data.Name, e.ExpiresAt.Format(time.RFC3339))
+		entry.Event, entry.Exemption = audit.EventExemptionExpired, e
+		auditLog.Log(entry)
+	}
+	if exemption == nil {
+		return false
+	}
+	entry.Event, entry.Exemption = audit.EventExemptionUsed, exemption
+	auditLog.Log(entry)
+	return true
+}
+
+//isDryRun reports whether the request won't be persisted, like kubectl apply --dry-run=server.
+//Dry-run requests don't send notifications and their audit entries are tagged as dry-run.
+func isDryRun(req *v1beta1.AdmissionRequest) bool {
+	return req.DryRun != nil && *req.DryRun
+}
+
+//ruleInput returns what rule definitions are evaluated against
+func ruleInput(req *v1beta1.AdmissionRequest) *rules.Input {
+	in := &rules.Input{
+		Object:    string(req.Object.Raw),
+		OldObject: string(req.OldObject.Raw),
+		NamespaceObject: func() map[string]interface{} {
+			return namespaces.Get(req.Namespace)
+		},
+	}
+	r := *req
+	r.Object, r.OldObject = runtime.RawExtension{}, runtime.RawExtension{}
+	if b, err := json.Marshal(r); err == nil {
+		json.Unmarshal(b, &in.Request)
+	}
+	return in
+}
+
+//messageData returns the request details available to every message template
+func messageData(req *v1beta1.AdmissionRequest, rsc *rules.Resource) messages.Data {
+	d := messages.Data{
+		Kind:      req.Kind.Kind,
+		Name:      req.Name,
+		Namespace: req.Namespace,
+		User:      req.UserInfo.Username,
+		Operation: string(req.Operation),
+		Cluster:   clusterName,
+	}
+	if d.Name == "" {
+		if name, ok := rsc.Metadata["name"].(string); ok && name != "" {
+			d.Name = name
+		} else if name, ok := rsc.Metadata["generateName"].(string); ok {
+			d.Name = name
+		}
+	}
+	return d
+}
+
+//renderViolation fills the violation texts using the rule's templates, falling back to the default ones on error
+func renderViolation(rule *rules.Rule, v *utils.Violation, d messages.Data) {
+	d.RuleName = v.RuleName
+	d.Description = v.Description
+	d.Field = v.JSONPath
+	d.Value = v.Value()
+	d.Error = v.Message
+	d.Path = v.Path
+	d.Element = v.Element
+	d.Container = v.Container
+	d.ContainerList = v.ContainerList
+
+	var err error
+	if v.Text, err = rule.RenderMessage(d); err != nil {
+		log.Printf("Could not render message template of rule %s: %v", rule.Name, err)
+		v.Text, _ = messages.RenderMessage(nil, d)
+	}
+	if v.Notification, err = rule.RenderNotification(d); err != nil {
+		log.Printf("Could not render notification template of rule %s: %v", rule.Name, err)
+		v.Notification, _ = messages.RenderNotification(nil, d)
+	}
+}
+
 func printValidationErrors(v []*utils.Violation) string {
 	sb := strings.Builder{}
 	for _, violation := range v {
-		m := fmt.Sprintf("\trule name: '%s', field: '%s', description: '%s', message: %s\n", violation.RuleName, violation.JSONPath, violation.Description, violation.Message)
-		sb.WriteString(m)
+		sb.WriteString("\t" + violation.Text + "\n")
 	}
 	defer sb.Reset()
 	return strings.TrimRight(sb.String(), "\n")
@@ -127,25 +311,40 @@ func handleAdmissionRequest(w http.ResponseWriter, r *http.Request, v validation
 	}
 
 	violatedRules := validateRules(admissionReviewReq.Request)
-	if len(violatedRules) > 0 {
+	dryRun := isDryRun(admissionReviewReq.Request)
+	if dryRun && dryRunDiagnostics {
+		for _, violation := range violatedRules {
+			violation.Text += "\n\t\t" + diagnostics(violation)
+		}
+	}
+	var denied, warned []*utils.Violation
+	for _, violation := range violatedRules {
+		if violation.EnforcementMode == rules.EnforcementWarn {
+			warned = append(warned, violation)
+		} else {
+			denied = append(denied, violation)
+		}
+	}
+	if len(denied) > 0 {
 		admissionReviewResponse.Response = &v1beta1.AdmissionResponse{
+			UID:     admissionReviewReq.Request.UID,
 			Allowed: false,
 			Result: &metav1.Status{
-				Message: fmt.Sprintf("We found violations in your request. The following rules were violated: \n %s", printValidationErrors(violatedRules)),
+				Message: fmt.Sprintf("We found violations in your request. The following rules were violated: \n %s", printValidationErrors(denied)),
 				Code:    http.StatusForbidden,
 			},
 		}
-		var msg notifications.NotificationMessage
-		for _, violation := range violatedRules {
-			msg.Message = fmt.Sprintf("Rule name: *%s*\n Rule Description: *%s*\n", violation.RuleName, violation.Description)
-			msg.ResourceType = admissionReviewReq.Request.Kind.Kind
-			msg.ResourceNamespace = admissionReviewReq.Request.Namespace
-			go notifications.NotifyViolation(msg, slackToken, violation.SlackChannel, "#FD0D0D")
-		}
-	} else if len(violatedRules) == 0 {
-		fmt.Printf("There was no violations!")
+	} else {
 		admissionReviewResponse.Response.Allowed = true
 	}
+	for _, violation := range warned {
+		admissionReviewResponse.Response.Warnings = append(admissionReviewResponse.Response.Warnings, violation.Text)
+	}
+	if dryRun && len(violatedRules) > 0 {
+		log.Printf("Not notifying %d violation(s) of dry-run request %s", len(violatedRules), admissionReviewReq.Request.UID)
+	} else if len(violatedRules) > 0 {
+		notifyViolations(admissionReviewReq.Request, violatedRules)
+	}
 	response, err := json.Marshal(admissionReviewResponse)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling response: %q", err)
@@ -153,6 +352,82 @@ func handleAdmissionRequest(w http.ResponseWriter, r *http.Request, v validation
 	return response, nil
 }
 
+//notifyViolations enqueues a notification for every violation of a rule with a notification channel
+func notifyViolations(req *v1beta1.AdmissionRequest, violations []*utils.Violation) {
+	if notifier == nil {
+		return
+	}
+	rsc := rules.Resource{}
+	json.Unmarshal(req.Object.Raw, &rsc)
+	data := messageData(req, &rsc)
+	for _, violation := range violations {
+		if violation.SlackChannel == "" {
+			continue
+		}
+		msg := notifications.NotificationMessage{
+			Message:           violation.Notification,
+			ResourceType:      data.Kind,
+			ResourceNamespace: data.Namespace,
+			ResourceName:      data.Name,
+			User:              data.User,
+			Operation:         data.Operation,
+			Cluster:           data.Cluster,
+			Field:             violationPath(violation),
+			Element:           elementName(violation),
+			Container:         containerName(violation),
+			Value:             notifications.FormatValue(violation.Value()),
+			RemediationURL:    violation.RemediationURL,
+			EnforcementMode:   violation.EnforcementMode,
+		}
+		if err := notifier.Enqueue(msg, violation.SlackChannel); err != nil {
+			log.Printf("Dropping notification for rule %s: %v", violation.RuleName, err)
+		}
+	}
+}
+
+//diagnostics describes the rule, path, value and errors of a violation, returned to dry-run requests with --dry-run-diagnostics
+func diagnostics(v *utils.Violation) string {
+	parts := []string{"rule: " + v.RuleName, "path: " + violationPath(v)}
+	if element := elementName(v); element != "" {
+		parts = append(parts, "element: "+element)
+	}
+	if container := containerName(v); container != "" {
+		parts = append(parts, "container: "+container)
+	}
+	parts = append(parts, "value: "+notifications.FormatValue(v.Value()))
+	if v.Errors != nil {
+		parts = append(parts, "errors: "+notifications.FormatValue(v.Errors))
+	}
+	if v.Message != "" {
+		parts = append(parts, "error: "+v.Message)
+	}
+	return strings.Join(parts, ", ")
+}
+
+//violationPath returns where the violating value is, or the field of the rule when it is not known
+func violationPath(v *utils.Violation) string {
+	if v.Path != "" {
+		return v.Path
+	}
+	return v.JSONPath
+}
+
+//elementName returns the name of the element holding the violating value, unless it is the violating container
+func elementName(v *utils.Violation) string {
+	if v.ContainerList != "" && v.Element == v.Container {
+		return ""
+	}
+	return v.Element
+}
+
+//containerName describes the container that violated a definition scoped to containers, like init (initContainers)
+func containerName(v *utils.Violation) string {
+	if v.ContainerList == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", v.Container, v.ContainerList)
+}
+
 func serveAdmitFunc(w http.ResponseWriter, r *http.Request, v validationFunc) {
 	log.Print("Handling webhook request ...")
 
@@ -171,36 +446,15 @@ func serveAdmitFunc(w http.ResponseWriter, r *http.Request, v validationFunc) {
 	}
 }
 
+//admitFuncHandler serves admission requests, a panic fails the request instead of the whole server
 func admitFuncHandler(v validationFunc) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("Panic handling webhook request: %v\n%s", err, debug.Stack())
+				http.Error(w, fmt.Sprintf("could not handle the admission request: %v", err), http.StatusInternalServerError)
+			}
+		}()
 		serveAdmitFunc(w, r, v)
 	})
 }
-
-func serve(cmd *cobra.Command, args []string) {
-	tlsCert, err := filepath.Abs(tlsCertPath)
-	if err != nil {
-		panic(err)
-	}
-	tlsKey, err := filepath.Abs(tlsKeyPath)
-	if err != nil {
-		panic(err)
-	}
-	rl := rules.RulesLoader(rulesFile)
-	rules.BuildRuleStore(&rl)
-	mux := http.NewServeMux()
-
-	// Dummy endpoint for livenessProbes
-	up := func(w http.ResponseWriter, _ *http.Request) {
-		io.WriteString(w, "UP\n")
-	}
-	mux.HandleFunc("/healthcheck", up)
-	mux.Handle("/admission", admitFuncHandler(validateRules))
-	server := &http.Server{
-		// We listen on port 8443 such that we do not need root privileges or extra capabilities for this server.
-		// The Service object will take care of mapping this port to the HTTPS port 443.
-		Addr:    fmt.Sprintf(":%s", listenPort),
-		Handler: mux,
-	}
-	log.Fatal(server.ListenAndServeTLS(tlsCert, tlsKey))
-}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grupozap/aegir/internal/pkg/rules"
+	livr "github.com/k33nice/go-livr"
+	"gotest.tools/assert"
+	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var buildRules sync.Once
+
+//testRules stores a permissive LIVR rule and a CEL rule denying hostNetwork in team-a, a rule that fails to evaluate in team-b,
+//in team-c a rule only warning about hostNetwork next to a rule denying pods named forbidden,
+//and in team-d a rule denying the deletion of pods named protected
+func testRules() {
+	buildRules.Do(func() {
+		rules.BuildRuleStore(&rules.RulesList{Rules: []*rules.Rule{
+			{
+				Name:         "named",
+				Namespace:    "team-a",
+				ResourceType: "Pod",
+				RulesDefinitions: []rules.RuleDefinition{{
+					Field:    "metadata.name",
+					LivrRule: rules.RuleObject{Description: "Pods must be named", RuleObj: livr.Dictionary{"name": "string"}},
+				}},
+			},
+			{
+				Name:         "no_host_network",
+				Namespace:    "team-a",
+				ResourceType: "Pod",
+				RulesDefinitions: []rules.RuleDefinition{{
+					CEL: &rules.CELRule{Description: "Pods can't use the host network", Expression: "!has(object.spec.hostNetwork) || !object.spec.hostNetwork"},
+				}},
+			},
+			{
+				Name:          "broken",
+				Namespace:     "team-b",
+				ResourceType:  "Pod",
+				FailurePolicy: rules.FailurePolicyAllow,
+				RulesDefinitions: []rules.RuleDefinition{{
+					CEL: &rules.CELRule{Description: "Returns a string", Expression: "object.metadata.name"},
+				}},
+			},
+			{
+				Name:            "host_network_warned",
+				Namespace:       "team-c",
+				ResourceType:    "Pod",
+				EnforcementMode: rules.EnforcementWarn,
+				RulesDefinitions: []rules.RuleDefinition{{
+					CEL: &rules.CELRule{Description: "Pods shouldn't use the host network", Expression: "!has(object.spec.hostNetwork) || !object.spec.hostNetwork"},
+				}},
+			},
+			{
+				Name:         "not_forbidden",
+				Namespace:    "team-c",
+				ResourceType: "Pod",
+				RulesDefinitions: []rules.RuleDefinition{{
+					CEL: &rules.CELRule{Description: "Pods can't be named forbidden", Expression: "object.metadata.name != 'forbidden'"},
+				}},
+			},
+			{
+				Name:         "not_protected",
+				Namespace:    "team-d",
+				ResourceType: "Pod",
+				Operations:   []string{"DELETE"},
+				RulesDefinitions: []rules.RuleDefinition{{
+					CEL: &rules.CELRule{Description: "Pods named protected can't be deleted", Expression: "oldObject.metadata.name != 'protected'"},
+				}},
+			},
+		}})
+	})
+}
+
+func podRequest(namespace, pod string) *v1beta1.AdmissionRequest {
+	return &v1beta1.AdmissionRequest{
+		UID:       "705ab4f5-6393-11e8-b7cc-42010a800002",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Namespace: namespace,
+		Operation: v1beta1.Create,
+		Object:    runtime.RawExtension{Raw: []byte(pod)},
+	}
+}
+
+const (
+	plainPod       = `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "app", "namespace": "team-a"}, "spec": {"containers": [{"name": "app", "image": "app:1.0"}]}}`
+	hostNetworkPod = `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "app", "namespace": "team-a"}, "spec": {"hostNetwork": true, "containers": [{"name": "app", "image": "app:1.0"}]}}`
+)
+
+//admit sends an AdmissionReview of req to handleAdmissionRequest and returns its response
+func admit(t *testing.T, req *v1beta1.AdmissionRequest) *v1beta1.AdmissionResponse {
+	body, err := json.Marshal(v1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+		Request:  req,
+	})
+	assert.NilError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/admission", bytes.NewReader(body))
+	r.Header.Set("Content-Type", jsonContentType)
+	response, err := handleAdmissionRequest(httptest.NewRecorder(), r, validateRules)
+	assert.NilError(t, err)
+	var review v1beta1.AdmissionReview
+	assert.NilError(t, json.Unmarshal(response, &review))
+	assert.Equal(t, review.Response.UID, req.UID)
+	return review.Response
+}
+
+func TestValidateRules(t *testing.T) {
+	testRules()
+	assert.Equal(t, len(validateRules(podRequest("team-a", plainPod))), 0)
+
+	violations := validateRules(podRequest("team-a", hostNetworkPod))
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].RuleName, "no_host_network")
+	assert.Assert(t, !violations[0].EvaluationError)
+
+	violations = validateRules(podRequest("team-a", `{"kind": "Pod", "metadata": {`))
+	assert.Equal(t, len(violations), 2)
+	for _, v := range violations {
+		assert.Assert(t, v.EvaluationError)
+		assert.Equal(t, v.EnforcementMode, rules.EnforcementDeny)
+	}
+
+	violations = validateRules(podRequest("team-a", `{"kind": "Pod", "metadata": "app"}`))
+	assert.Equal(t, len(violations), 2)
+	for _, v := range violations {
+		assert.Assert(t, v.EvaluationError)
+		assert.Assert(t, strings.Contains(v.Message, "could not decode the object"), v.Message)
+	}
+
+	//Rules without operations are only evaluated on CREATE and UPDATE
+	req := podRequest("team-a", "")
+	req.Operation = v1beta1.Delete
+	assert.Equal(t, len(validateRules(req)), 0)
+
+	protected := `{"kind": "Pod", "metadata": {"name": "protected"}}`
+	assert.Equal(t, len(validateRules(podRequest("team-d", protected))), 0)
+	req = podRequest("team-d", "")
+	req.Operation = v1beta1.Delete
+	req.OldObject = runtime.RawExtension{Raw: []byte(protected)}
+	violations = validateRules(req)
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].RuleName, "not_protected")
+}
+
+func TestHandleAdmissionRequest(t *testing.T) {
+	testRules()
+	response := admit(t, podRequest("team-a", plainPod))
+	assert.Assert(t, response.Allowed)
+
+	response = admit(t, podRequest("team-a", hostNetworkPod))
+	assert.Assert(t, !response.Allowed)
+	assert.Equal(t, response.Result.Code, int32(http.StatusForbidden))
+	assert.Assert(t, strings.Contains(response.Result.Message, "no_host_network"), response.Result.Message)
+
+	response = admit(t, podRequest("team-b", plainPod))
+	assert.Assert(t, response.Allowed)
+	assert.Equal(t, len(response.Warnings), 1)
+	assert.Assert(t, strings.Contains(response.Warnings[0], "instead of a bool"), response.Warnings[0])
+}
+
+func TestHandleAdmissionRequestWarnMode(t *testing.T) {
+	testRules()
+	response := admit(t, podRequest("team-c", plainPod))
+	assert.Assert(t, response.Allowed)
+	assert.Equal(t, len(response.Warnings), 0)
+
+	response = admit(t, podRequest("team-c", hostNetworkPod))
+	assert.Assert(t, response.Allowed)
+	assert.Assert(t, response.Result == nil)
+	assert.Equal(t, len(response.Warnings), 1)
+	assert.Assert(t, strings.Contains(response.Warnings[0], "host_network_warned"), response.Warnings[0])
+
+	response = admit(t, podRequest("team-c", strings.Replace(hostNetworkPod, `"name": "app", "namespace"`, `"name": "forbidden", "namespace"`, 1)))
+	assert.Assert(t, !response.Allowed)
+	assert.Equal(t, response.Result.Code, int32(http.StatusForbidden))
+	assert.Assert(t, strings.Contains(response.Result.Message, "not_forbidden"), response.Result.Message)
+	assert.Assert(t, !strings.Contains(response.Result.Message, "host_network_warned"), response.Result.Message)
+	assert.Equal(t, len(response.Warnings), 1)
+	assert.Assert(t, strings.Contains(response.Warnings[0], "host_network_warned"), response.Warnings[0])
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/grupozap/aegir/internal/pkg/kinds"
+	"github.com/grupozap/aegir/internal/pkg/lint"
+	"github.com/grupozap/aegir/internal/pkg/podsecurity"
+	"github.com/grupozap/aegir/internal/pkg/rules"
+	"github.com/grupozap/aegir/internal/pkg/schema"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exemptionsFile string
+	discoveryFiles []string
+	openAPIFiles   []string
+	strictRules    bool
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Reports rules that can never fire.",
+	Run:   lintRules,
+}
+
+func init() {
+	RootCmd.AddCommand(lintCmd)
+	lintCmd.Flags().StringVar(&rulesFile, "rules-file", "", "File that contains the rules that will be applied for the Kubernetes resources.")
+	lintCmd.Flags().StringVar(&exemptionsFile, "exemptions-file", "", "File that contains exemptions waiving rules, in addition to the ones of the rules file.")
+	lintCmd.Flags().StringSliceVar(&discoveryFiles, "discovery-file", nil, "APIResourceList files describing kinds that are not built-in, like CRDs. Eg: kubectl get --raw /apis/cert-manager.io/v1")
+	lintCmd.Flags().StringSliceVar(&openAPIFiles, "openapi-file", nil, "OpenAPI v2 or v3 documents used to check that fields exist, instead of the bundled schemas. Eg: kubectl get --raw /openapi/v2")
+}
+
+//registerDiscovery makes the kinds described by --discovery-file known
+func registerDiscovery() {
+	for _, f := range discoveryFiles {
+		ks, err := kinds.LoadDiscovery(f)
+		if err != nil {
+			log.Fatalf("could not load discovery file %s: %v", f, err)
+		}
+		kinds.Register(ks...)
+	}
+}
+
+//registerSchemas makes the schemas described by --openapi-file known
+func registerSchemas() {
+	for _, f := range openAPIFiles {
+		ks, err := schema.LoadOpenAPI(f)
+		if err != nil {
+			log.Fatalf("could not load OpenAPI file %s: %v", f, err)
+		}
+		schema.Register(ks)
+	}
+}
+
+//loadRules reads --rules-file and appends the rules of the packs it enables and the exemptions of --exemptions-file
+func loadRules() rules.RulesList {
+	rl := rules.RulesLoader(rulesFile)
+	if err := podsecurity.Expand(&rl); err != nil {
+		log.Fatalf("could not load %s: %v", rulesFile, err)
+	}
+	if exemptionsFile != "" {
+		exemptions, err := rules.LoadExemptions(exemptionsFile)
+		if err != nil {
+			log.Fatalf("could not load exemptions file %s: %v", exemptionsFile, err)
+		}
+		rl.Exemptions = append(rl.Exemptions, exemptions...)
+	}
+	return rl
+}
+
+//logDiagnostics reports rules that can never fire, stopping Aegir only when --strict-rules is set
+func logDiagnostics(rl *rules.RulesList) {
+	diagnostics := lint.Lint(rl)
+	for _, d := range diagnostics {
+		log.Printf("WARNING: %s", d)
+	}
+	if strictRules && len(diagnostics) > 0 {
+		log.Fatalf("Found %d problem(s) in %s, refusing to start with --strict-rules", len(diagnostics), rulesFile)
+	}
+}
+
+func lintRules(cmd *cobra.Command, args []string) {
+	registerDiscovery()
+	registerSchemas()
+	rl := loadRules()
+	diagnostics := lint.Lint(&rl)
+	if len(diagnostics) == 0 {
+		fmt.Println("No problems found.")
+		return
+	}
+	fmt.Println(lint.Format(diagnostics))
+	os.Exit(1)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+//namespaceCacheTTL is how long a Namespace read by CEL expressions is reused
+const namespaceCacheTTL = time.Minute
+
+//namespaces serves the namespaceObject of CEL expressions, nil when no expression uses it
+var namespaces *namespaceCache
+
+type cachedNamespace struct {
+	object  map[string]interface{}
+	fetched time.Time
+}
+
+//namespaceCache reads Namespaces from the API server, keeping them for namespaceCacheTTL
+type namespaceCache struct {
+	client kubernetes.Interface
+	mu     sync.Mutex
+	cache  map[string]cachedNamespace
+}
+
+func newNamespaceCache(client kubernetes.Interface) *namespaceCache {
+	return &namespaceCache{client: client, cache: map[string]cachedNamespace{}}
+}
+
+//Get returns the Namespace as a map, or nil when it can't be read
+func (c *namespaceCache) Get(name string) map[string]interface{} {
+	if c == nil || name == "" {
+		return nil
+	}
+	c.mu.Lock()
+	cached, ok := c.cache[name]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetched) < namespaceCacheTTL {
+		return cached.object
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ns, err := c.client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("Could not read namespace %s: %v", name, err)
+		return nil
+	}
+	var object map[string]interface{}
+	b, _ := json.Marshal(ns)
+	if err := json.Unmarshal(b, &object); err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	c.cache[name] = cachedNamespace{object: object, fetched: time.Now()}
+	c.mu.Unlock()
+	return object
+}
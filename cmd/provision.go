@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/grupozap/aegir/internal/pkg/certs"
+	"github.com/grupozap/aegir/internal/pkg/rules"
+	"github.com/grupozap/aegir/internal/pkg/webhook"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func kubernetesClient() (kubernetes.Interface, error) {
+	var config *rest.Config
+	var err error
+	if kubeconfig != "" {
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	} else {
+		config, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+//provision generates or reuses the serving certificate stored in a Secret and registers
+//Aegir's webhook with the matching caBundle. The certificate is renewed every --tls-reload-interval
+//when it is about to expire, until ctx is done.
+func provision(ctx context.Context, rl *rules.RulesList) getCertificateFunc {
+	client, err := kubernetesClient()
+	if err != nil {
+		log.Fatalf("could not create Kubernetes client: %v", err)
+	}
+	p := &webhook.Provisioner{
+		Client:     client,
+		SecretName: tlsSecretName,
+		Webhook: webhook.Options{
+			Name:             webhookConfigName,
+			ServiceName:      serviceName,
+			ServiceNamespace: serviceNamespace,
+		},
+	}
+
+	var caBundle []byte
+	load := func() ([]byte, []byte, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		serving, ca, err := p.EnsureCertificate(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not provision TLS certificate: %v", err)
+		}
+		if !bytes.Equal(caBundle, ca.CertPEM) {
+			if err := p.EnsureWebhookConfiguration(ctx, rl, ca.CertPEM); err != nil {
+				return nil, nil, fmt.Errorf("could not provision ValidatingWebhookConfiguration: %v", err)
+			}
+			caBundle = ca.CertPEM
+		}
+		return serving.CertPEM, serving.KeyPEM, nil
+	}
+	reloader, err := certs.NewReloaderFunc(fmt.Sprintf("secret %s/%s", serviceNamespace, tlsSecretName), load)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go reloader.Watch(ctx, tlsReloadInterval)
+	return reloader.GetCertificate
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/grupozap/aegir/internal/pkg/audit"
+	"github.com/grupozap/aegir/internal/pkg/certs"
+	notifications "github.com/grupozap/aegir/internal/pkg/notifications/slack"
+	"github.com/grupozap/aegir/internal/pkg/rules"
+	"github.com/spf13/cobra"
+)
+
+//ready is 1 while Aegir accepts new admission requests and 0 once it started shutting down
+var ready int32
+
+func readiness(w http.ResponseWriter, _ *http.Request) {
+	if atomic.LoadInt32(&ready) == 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "NOT READY\n")
+		return
+	}
+	io.WriteString(w, "READY\n")
+}
+
+type getCertificateFunc func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+//watchCertificate loads the certificate passed by flags and keeps reloading it until ctx is done
+func watchCertificate(ctx context.Context) getCertificateFunc {
+	tlsCert, err := filepath.Abs(tlsCertPath)
+	if err != nil {
+		panic(err)
+	}
+	tlsKey, err := filepath.Abs(tlsKeyPath)
+	if err != nil {
+		panic(err)
+	}
+	reloader, err := certs.NewReloader(tlsCert, tlsKey)
+	if err != nil {
+		log.Fatalf("could not load TLS certificate: %v", err)
+	}
+	go reloader.Watch(ctx, tlsReloadInterval)
+	return reloader.GetCertificate
+}
+
+//openAuditLog returns the audit log written to --audit-log-file, or to the standard error
+func openAuditLog() *audit.Logger {
+	if auditLogFile == "" {
+		return audit.NewLogger(os.Stderr)
+	}
+	f, err := os.OpenFile(auditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("could not open audit log file %s: %v", auditLogFile, err)
+	}
+	return audit.NewLogger(f)
+}
+
+func serve(cmd *cobra.Command, args []string) {
+	registerDiscovery()
+	registerSchemas()
+	rl := loadRules()
+	rules.BuildRuleStore(&rl)
+	rules.BuildExemptionStore(&rl)
+	logDiagnostics(&rl)
+	auditLog = openAuditLog()
+	if rules.UsesNamespaceObject(&rl) {
+		client, err := kubernetesClient()
+		if err != nil {
+			log.Fatalf("could not create Kubernetes client to read namespaceObject: %v", err)
+		}
+		namespaces = newNamespaceCache(client)
+	}
+
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	var getCertificate getCertificateFunc
+	if selfProvision {
+		getCertificate = provision(watchCtx, &rl)
+	} else {
+		getCertificate = watchCertificate(watchCtx)
+	}
+	if slackToken != "" {
+		notifier = notifications.NewNotifier(slackToken, notificationOpts)
+	}
+	mux := http.NewServeMux()
+
+	// Dummy endpoint for livenessProbes
+	up := func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "UP\n")
+	}
+	mux.HandleFunc("/healthcheck", up)
+	mux.HandleFunc("/readiness", readiness)
+	mux.Handle("/admission", admitFuncHandler(validateRules))
+	mux.Handle("/metrics", expvar.Handler())
+	server := &http.Server{
+		// We listen on port 8443 such that we do not need root privileges or extra capabilities for this server.
+		// The Service object will take care of mapping this port to the HTTPS port 443.
+		Addr:         fmt.Sprintf(":%s", listenPort),
+		Handler:      mux,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+		TLSConfig: &tls.Config{
+			GetCertificate: getCertificate,
+		},
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServeTLS("", "")
+	}()
+	atomic.StoreInt32(&ready, 1)
+
+	select {
+	case err := <-serverErr:
+		log.Fatal(err)
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down ...", sig)
+	}
+	shutdown(server)
+}
+
+//shutdown flips the readiness probe, waits for the drain period so the API server stops
+//sending requests, then waits for in-flight requests and pending notifications
+func shutdown(server *http.Server) {
+	atomic.StoreInt32(&ready, 0)
+	if shutdownDrainPeriod > 0 {
+		log.Printf("Waiting %s before closing the listener ...", shutdownDrainPeriod)
+		time.Sleep(shutdownDrainPeriod)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Could not finish every in-flight request: %v", err)
+	}
+
+	if notifier != nil {
+		log.Print("Draining pending notifications ...")
+		ctx, cancel := context.WithTimeout(context.Background(), notificationDrainTimeout)
+		defer cancel()
+		if err := notifier.Close(ctx); err != nil {
+			log.Printf("Could not deliver every pending notification: %v", err)
+		}
+	}
+	log.Print("Aegir stopped")
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"os"
+
+	y2j "github.com/ghodss/yaml"
+	"github.com/grupozap/aegir/internal/pkg/webhook"
+	"github.com/spf13/cobra"
+)
+
+var caBundleFile string
+var namespaceLabel string
+var webhookConfigOutput string
+
+var webhookConfigCmd = &cobra.Command{
+	Use:   "webhook-config",
+	Short: "Prints the ValidatingWebhookConfiguration that routes the resources used by the rules to Aegir.",
+	Run:   printWebhookConfig,
+}
+
+func init() {
+	RootCmd.AddCommand(webhookConfigCmd)
+	webhookConfigCmd.Flags().StringVar(&rulesFile, "rules-file", "", "File that contains the rules that will be applied for the Kubernetes resources.")
+	webhookConfigCmd.Flags().StringSliceVar(&discoveryFiles, "discovery-file", nil, "APIResourceList files describing kinds that are not built-in, like CRDs.")
+	webhookConfigCmd.Flags().StringVar(&caBundleFile, "ca-bundle-file", "", "PEM encoded CA certificate that signed Aegir's serving certificate.")
+	webhookConfigCmd.Flags().StringVar(&serviceName, "service-name", "aegir", "Name of the Service exposing Aegir.")
+	webhookConfigCmd.Flags().StringVar(&serviceNamespace, "service-namespace", "default", "Namespace of the Service exposing Aegir.")
+	webhookConfigCmd.Flags().StringVar(&webhookConfigName, "webhook-config-name", "aegir-webhook", "Name of the ValidatingWebhookConfiguration.")
+	webhookConfigCmd.Flags().StringVar(&namespaceLabel, "namespace-label", webhook.DefaultNamespaceLabel, "Namespace label holding the namespace name used in the namespaceSelector, an empty value disables the selector.")
+	webhookConfigCmd.Flags().StringVarP(&webhookConfigOutput, "output", "o", "", "File the manifest is written to, defaults to stdout.")
+}
+
+//manifest renders obj as YAML, leaving out the fields that only make sense for objects read from the API
+func manifest(obj interface{}) ([]byte, error) {
+	j, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(j, &m); err != nil {
+		return nil, err
+	}
+	if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+	return y2j.Marshal(m)
+}
+
+func printWebhookConfig(cmd *cobra.Command, args []string) {
+	registerDiscovery()
+	rl := loadRules()
+	opts := webhook.Options{
+		Name:              webhookConfigName,
+		ServiceName:       serviceName,
+		ServiceNamespace:  serviceNamespace,
+		NamespaceLabel:    namespaceLabel,
+		SkippedNamespaces: skippedNamespaces,
+	}
+	if caBundleFile != "" {
+		ca, err := ioutil.ReadFile(caBundleFile)
+		if err != nil {
+			log.Fatalf("could not read CA bundle: %v", err)
+		}
+		if block, _ := pem.Decode(ca); block == nil || block.Type != "CERTIFICATE" {
+			log.Fatalf("%s is not a PEM encoded certificate", caBundleFile)
+		}
+		opts.CABundle = ca
+	}
+
+	out, err := manifest(webhook.Configuration(&rl, opts))
+	if err != nil {
+		log.Fatalf("could not render ValidatingWebhookConfiguration: %v", err)
+	}
+	if webhookConfigOutput == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := ioutil.WriteFile(webhookConfigOutput, out, 0644); err != nil {
+		log.Fatalf("could not write ValidatingWebhookConfiguration: %v", err)
+	}
+}
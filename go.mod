@@ -3,13 +3,20 @@ module github.com/grupozap/aegir
 go 1.15
 
 require (
+	github.com/Masterminds/semver v1.5.0
+	github.com/docker/distribution v2.7.1+incompatible
 	github.com/ghodss/yaml v1.0.0
+	github.com/google/cel-go v0.6.0
 	github.com/k33nice/go-livr v2.0.0+incompatible
 	github.com/nlopes/slack v0.6.0
+	github.com/open-policy-agent/opa v0.24.0
+	github.com/opencontainers/go-digest v1.0.0 // indirect
 	github.com/spf13/cobra v1.0.0
-	github.com/tidwall/gjson v1.6.1
+	github.com/tidwall/gjson v1.14.4
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013
 	gopkg.in/yaml.v2 v2.3.0
 	gotest.tools v2.2.0+incompatible
 	k8s.io/api v0.19.2
 	k8s.io/apimachinery v0.19.2
+	k8s.io/client-go v0.19.2
 )
@@ -0,0 +1,67 @@
+//Package audit records decisions Aegir takes on behalf of someone, like waiving a violation, as JSON lines
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	//EventExemptionUsed is recorded when an exemption waives the violations of a rule
+	EventExemptionUsed = "exemption_used"
+	//EventExemptionExpired is recorded when an expired exemption would have waived the violations of a rule
+	EventExemptionExpired = "exemption_expired"
+	//EventFailureAllowed is recorded when a rule that could not be evaluated allows the request, following its failure policy
+	EventFailureAllowed = "failure_allowed"
+)
+
+//Entry is a line of the audit log
+type Entry struct {
+	Time       time.Time   `json:"time"`
+	Event      string      `json:"event"`
+	Rule       string      `json:"rule"`
+	UID        string      `json:"uid,omitempty"`
+	Kind       string      `json:"kind,omitempty"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Name       string      `json:"name,omitempty"`
+	User       string      `json:"user,omitempty"`
+	Operation  string      `json:"operation,omitempty"`
+	Violations int         `json:"violations,omitempty"`
+	Exemption  interface{} `json:"exemption,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DryRun     bool        `json:"dry_run,omitempty"`
+}
+
+//Logger writes entries to w, one JSON document per line
+type Logger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+//NewLogger returns a Logger writing to w
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+//Log writes an entry, setting its time when unset. A nil Logger discards it.
+func (l *Logger) Log(e Entry) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Could not write audit entry %s of rule %s: %v", e.Event, e.Rule, err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(b, '\n')); err != nil {
+		log.Printf("Could not write audit entry %s of rule %s: %v", e.Event, e.Rule, err)
+	}
+}
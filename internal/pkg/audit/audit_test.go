@@ -0,0 +1,31 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestLog(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLogger(&b)
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	l.Log(Entry{Time: at, Event: EventExemptionUsed, Rule: "no_latest", Namespace: "payments", Name: "api", Violations: 2})
+	l.Log(Entry{Event: EventExemptionExpired, Rule: "no_latest"})
+	l.Log(Entry{Time: at, Event: EventExemptionUsed, Rule: "no_latest", DryRun: true})
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Equal(t, len(lines), 3)
+	assert.Equal(t, lines[0], `{"time":"2026-10-19T12:00:00Z","event":"exemption_used","rule":"no_latest","namespace":"payments","name":"api","violations":2}`)
+	var e Entry
+	assert.NilError(t, json.Unmarshal([]byte(lines[1]), &e))
+	assert.Assert(t, !e.Time.IsZero())
+	assert.Equal(t, lines[2], `{"time":"2026-10-19T12:00:00Z","event":"exemption_used","rule":"no_latest","dry_run":true}`)
+
+	var nilLogger *Logger
+	nilLogger.Log(Entry{Event: EventExemptionUsed})
+}
@@ -0,0 +1,137 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"time"
+)
+
+//KeyPair is a PEM encoded certificate and its private key
+type KeyPair struct {
+	CertPEM []byte
+	KeyPEM  []byte
+
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+//Certificate returns the parsed certificate of the key pair
+func (kp *KeyPair) Certificate() *x509.Certificate {
+	return kp.cert
+}
+
+//GenerateCA creates a self-signed certificate authority valid for validity
+func GenerateCA(commonName string, validity time.Duration) (*KeyPair, error) {
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return generate(tmpl, validity, nil)
+}
+
+//Sign creates a serving certificate for dnsNames signed by the certificate authority kp
+func (kp *KeyPair) Sign(dnsNames []string, validity time.Duration) (*KeyPair, error) {
+	if len(dnsNames) == 0 {
+		return nil, errors.New("at least one DNS name is required")
+	}
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:    dnsNames,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	return generate(tmpl, validity, kp)
+}
+
+func generate(tmpl *x509.Certificate, validity time.Duration, parent *KeyPair) (*KeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	tmpl.SerialNumber = serial
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(validity)
+
+	parentCert, signer := tmpl, crypto.Signer(key)
+	if parent != nil {
+		parentCert, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, key.Public(), signer)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		cert:    cert,
+		key:     key,
+	}, nil
+}
+
+//ParseKeyPair decodes a PEM encoded certificate and private key
+func ParseKeyPair(certPEM, keyPEM []byte) (*KeyPair, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, errors.New("could not decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, errors.New("could not decode private key PEM")
+	}
+	var key crypto.Signer
+	if k, err := x509.ParseECPrivateKey(keyBlock.Bytes); err == nil {
+		key = k
+	} else if k, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes); err == nil {
+		key = k
+	} else if k, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes); err == nil {
+		signer, ok := k.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		key = signer
+	} else {
+		return nil, errors.New("could not parse private key")
+	}
+	return &KeyPair{CertPEM: certPEM, KeyPEM: keyPEM, cert: cert, key: key}, nil
+}
+
+//ValidFor reports whether the certificate is signed by ca, covers dnsNames and is valid for at least d
+func (kp *KeyPair) ValidFor(ca *KeyPair, dnsNames []string, d time.Duration) bool {
+	if time.Now().Add(d).After(kp.cert.NotAfter) {
+		return false
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	for _, name := range dnsNames {
+		opts := x509.VerifyOptions{DNSName: name, Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+		if _, err := kp.cert.Verify(opts); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+package certs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignAndParseKeyPair(t *testing.T) {
+	ca, err := GenerateCA("test CA", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	serving, err := ca.Sign([]string{"aegir.default.svc"}, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parsedCA, err := ParseKeyPair(ca.CertPEM, ca.KeyPEM)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parsed, err := ParseKeyPair(serving.CertPEM, serving.KeyPEM)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !parsed.ValidFor(parsedCA, []string{"aegir.default.svc"}, time.Minute) {
+		t.Error("expected the certificate to be valid for aegir.default.svc")
+	}
+	if parsed.ValidFor(parsedCA, []string{"other.default.svc"}, time.Minute) {
+		t.Error("expected the certificate not to be valid for other.default.svc")
+	}
+	if parsed.ValidFor(parsedCA, []string{"aegir.default.svc"}, 2*time.Hour) {
+		t.Error("expected the certificate not to be valid in two hours")
+	}
+	other, _ := GenerateCA("other CA", time.Hour)
+	if parsed.ValidFor(other, []string{"aegir.default.svc"}, time.Minute) {
+		t.Error("expected the certificate not to be valid for another CA")
+	}
+}
+
+func TestSignRequiresDNSNames(t *testing.T) {
+	ca, _ := GenerateCA("test CA", time.Hour)
+	if _, err := ca.Sign(nil, time.Hour); err == nil {
+		t.Error("expected an error signing without DNS names")
+	}
+}
@@ -0,0 +1,153 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"expvar"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+)
+
+//ExpiryWarningThreshold is how close to its expiration a certificate starts being reported in the logs
+var ExpiryWarningThreshold = 7 * 24 * time.Hour
+
+//metrics exposes reload counters and the expiration of the serving certificate through expvar
+var metrics = expvar.NewMap("tls")
+
+//Reloader serves a TLS key pair read from disk and reloads it whenever the files change,
+//as it happens when cert-manager or a Secret update rotates the certificate
+type Reloader struct {
+	//name identifies the key pair in the logs, like the path of the certificate
+	name string
+	load func() (certPEM, keyPEM []byte, err error)
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	notAfter time.Time
+	certPEM  []byte
+	keyPEM   []byte
+	warned   bool
+}
+
+//NewReloader loads the key pair from certFile and keyFile
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	return NewReloaderFunc(certFile, func() ([]byte, []byte, error) {
+		certPEM, err := ioutil.ReadFile(certFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		keyPEM, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return certPEM, keyPEM, nil
+	})
+}
+
+//NewReloaderFunc loads the key pair returned by load, which is called again on every reload.
+//It serves key pairs that are not read from files, like the ones renewed through the Kubernetes API.
+func NewReloaderFunc(name string, load func() (certPEM, keyPEM []byte, err error)) (*Reloader, error) {
+	r := &Reloader{name: name, load: load}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//GetCertificate returns the current key pair, it is meant to be used as tls.Config.GetCertificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+//NotAfter returns the expiration of the current certificate
+func (r *Reloader) NotAfter() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.notAfter
+}
+
+//Reload reads the key pair again and swaps it if it changed.
+//The current key pair is kept when the new one can't be loaded.
+func (r *Reloader) Reload() (bool, error) {
+	certPEM, keyPEM, err := r.load()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		metrics.Add("reload_errors", 1)
+		return false, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		metrics.Add("reload_errors", 1)
+		return false, err
+	}
+	cert.Leaf = leaf
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.notAfter = leaf.NotAfter
+	r.certPEM = certPEM
+	r.keyPEM = keyPEM
+	r.warned = false
+	r.mu.Unlock()
+
+	metrics.Add("reloads", 1)
+	notAfter := new(expvar.Int)
+	notAfter.Set(leaf.NotAfter.Unix())
+	metrics.Set("cert_not_after", notAfter)
+	metrics.Set("cert_expires_in_seconds", expvar.Func(func() interface{} {
+		return int64(time.Until(r.NotAfter()).Seconds())
+	}))
+	log.Printf("Loaded TLS certificate %s for %q, expires at %s", r.name, leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339))
+	r.checkExpiry()
+	return true, nil
+}
+
+//checkExpiry logs once per certificate when it is about to expire
+func (r *Reloader) checkExpiry() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	remaining := time.Until(r.notAfter)
+	if r.warned || remaining > ExpiryWarningThreshold {
+		return
+	}
+	r.warned = true
+	if remaining <= 0 {
+		log.Printf("TLS certificate %s expired at %s", r.name, r.notAfter.Format(time.RFC3339))
+		return
+	}
+	log.Printf("TLS certificate %s expires in %s", r.name, remaining.Round(time.Minute))
+}
+
+//Watch reloads the key pair every interval until ctx is done
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Reload(); err != nil {
+				log.Printf("Could not reload TLS certificate, keeping the current one: %v", err)
+			}
+			r.checkExpiry()
+		}
+	}
+}
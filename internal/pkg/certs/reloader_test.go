@@ -0,0 +1,129 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKeyPair(t *testing.T, dir, cn string, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "aegir-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestReloaderReloadsChangedFiles(t *testing.T) {
+	dir := tempDir(t)
+	certFile, keyFile := writeKeyPair(t, dir, "first", time.Now().Add(24*time.Hour))
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cert, _ := r.GetCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "first" {
+		t.Errorf("expected '%s' but got '%s'", "first", cert.Leaf.Subject.CommonName)
+	}
+
+	if changed, err := r.Reload(); changed || err != nil {
+		t.Errorf("expected no change but got changed=%t err=%v", changed, err)
+	}
+
+	notAfter := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	writeKeyPair(t, dir, "second", notAfter)
+	if changed, err := r.Reload(); !changed || err != nil {
+		t.Errorf("expected a reload but got changed=%t err=%v", changed, err)
+	}
+	cert, _ = r.GetCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "second" {
+		t.Errorf("expected '%s' but got '%s'", "second", cert.Leaf.Subject.CommonName)
+	}
+	if !r.NotAfter().Equal(notAfter) {
+		t.Errorf("expected '%s' but got '%s'", notAfter, r.NotAfter())
+	}
+}
+
+func TestReloaderKeepsCurrentCertificateOnError(t *testing.T) {
+	dir := tempDir(t)
+	certFile, keyFile := writeKeyPair(t, dir, "first", time.Now().Add(24*time.Hour))
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ioutil.WriteFile(keyFile, []byte("not a key"), 0600)
+	if _, err := r.Reload(); err == nil {
+		t.Error("expected an error loading an invalid key")
+	}
+	cert, _ := r.GetCertificate(nil)
+	if cert == nil || cert.Leaf.Subject.CommonName != "first" {
+		t.Errorf("expected the previous certificate to be kept but got '%v'", cert)
+	}
+}
+
+func TestNewReloaderMissingFiles(t *testing.T) {
+	if _, err := NewReloader("does-not-exist.crt", "does-not-exist.key"); err == nil {
+		t.Error("expected an error loading missing files")
+	}
+}
+
+func TestReloaderFunc(t *testing.T) {
+	ca, err := GenerateCA("ca", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	current, _ := ca.Sign([]string{"first"}, 24*time.Hour)
+	r, err := NewReloaderFunc("secret platform/aegir-tls", func() ([]byte, []byte, error) {
+		return current.CertPEM, current.KeyPEM, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed, err := r.Reload(); changed || err != nil {
+		t.Errorf("expected no change but got changed=%t err=%v", changed, err)
+	}
+	current, _ = ca.Sign([]string{"second"}, 24*time.Hour)
+	if changed, err := r.Reload(); !changed || err != nil {
+		t.Errorf("expected a reload but got changed=%t err=%v", changed, err)
+	}
+	cert, _ := r.GetCertificate(nil)
+	if cert.Leaf.DNSNames[0] != "second" {
+		t.Errorf("expected '%s' but got '%v'", "second", cert.Leaf.DNSNames)
+	}
+}
@@ -0,0 +1,53 @@
+package kinds
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	y2j "github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+//LoadDiscovery reads the kinds served by a cluster from a file holding one APIResourceList or a list of them,
+//in JSON or YAML, as returned by `kubectl get --raw /apis/<group>/<version>`
+func LoadDiscovery(path string) ([]Kind, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	j, err := y2j.YAMLToJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var lists []metav1.APIResourceList
+	if bytes.HasPrefix(bytes.TrimSpace(j), []byte("[")) {
+		err = json.Unmarshal(j, &lists)
+	} else {
+		var list metav1.APIResourceList
+		err = json.Unmarshal(j, &list)
+		lists = append(lists, list)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ks []Kind
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list.APIResources {
+			//Subresources like deployments/scale are not admitted as objects of their own
+			if strings.Contains(r.Name, "/") {
+				continue
+			}
+			ks = append(ks, Kind{Name: r.Kind, Groups: []string{gv.Group}, Resource: r.Name, Namespaced: r.Namespaced})
+		}
+	}
+	return ks, nil
+}
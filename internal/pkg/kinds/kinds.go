@@ -0,0 +1,115 @@
+package kinds
+
+import (
+	"sort"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+//Kind describes how a Kubernetes kind is served by the API server
+type Kind struct {
+	Name       string
+	Groups     []string
+	Resource   string
+	Namespaced bool
+	//Object is an empty value of the kind's Go type, nil for kinds without a built-in schema
+	Object interface{}
+}
+
+//builtin lists the kinds served by a vanilla Kubernetes API server, including the legacy groups some of them were served from
+var builtin = []Kind{
+	{Name: "Pod", Groups: []string{""}, Resource: "pods", Namespaced: true, Object: &corev1.Pod{}},
+	{Name: "Service", Groups: []string{""}, Resource: "services", Namespaced: true, Object: &corev1.Service{}},
+	{Name: "ConfigMap", Groups: []string{""}, Resource: "configmaps", Namespaced: true, Object: &corev1.ConfigMap{}},
+	{Name: "Secret", Groups: []string{""}, Resource: "secrets", Namespaced: true, Object: &corev1.Secret{}},
+	{Name: "ServiceAccount", Groups: []string{""}, Resource: "serviceaccounts", Namespaced: true, Object: &corev1.ServiceAccount{}},
+	{Name: "PersistentVolumeClaim", Groups: []string{""}, Resource: "persistentvolumeclaims", Namespaced: true, Object: &corev1.PersistentVolumeClaim{}},
+	{Name: "ReplicationController", Groups: []string{""}, Resource: "replicationcontrollers", Namespaced: true, Object: &corev1.ReplicationController{}},
+	{Name: "Endpoints", Groups: []string{""}, Resource: "endpoints", Namespaced: true, Object: &corev1.Endpoints{}},
+	{Name: "LimitRange", Groups: []string{""}, Resource: "limitranges", Namespaced: true, Object: &corev1.LimitRange{}},
+	{Name: "ResourceQuota", Groups: []string{""}, Resource: "resourcequotas", Namespaced: true, Object: &corev1.ResourceQuota{}},
+	{Name: "Namespace", Groups: []string{""}, Resource: "namespaces", Object: &corev1.Namespace{}},
+	{Name: "Node", Groups: []string{""}, Resource: "nodes", Object: &corev1.Node{}},
+	{Name: "PersistentVolume", Groups: []string{""}, Resource: "persistentvolumes", Object: &corev1.PersistentVolume{}},
+	{Name: "Deployment", Groups: []string{"apps", "extensions"}, Resource: "deployments", Namespaced: true, Object: &appsv1.Deployment{}},
+	{Name: "StatefulSet", Groups: []string{"apps"}, Resource: "statefulsets", Namespaced: true, Object: &appsv1.StatefulSet{}},
+	{Name: "DaemonSet", Groups: []string{"apps", "extensions"}, Resource: "daemonsets", Namespaced: true, Object: &appsv1.DaemonSet{}},
+	{Name: "ReplicaSet", Groups: []string{"apps", "extensions"}, Resource: "replicasets", Namespaced: true, Object: &appsv1.ReplicaSet{}},
+	{Name: "Job", Groups: []string{"batch"}, Resource: "jobs", Namespaced: true, Object: &batchv1.Job{}},
+	{Name: "CronJob", Groups: []string{"batch"}, Resource: "cronjobs", Namespaced: true, Object: &batchv1beta1.CronJob{}},
+	{Name: "Ingress", Groups: []string{"networking.k8s.io", "extensions"}, Resource: "ingresses", Namespaced: true, Object: &networkingv1.Ingress{}},
+	{Name: "NetworkPolicy", Groups: []string{"networking.k8s.io"}, Resource: "networkpolicies", Namespaced: true, Object: &networkingv1.NetworkPolicy{}},
+	{Name: "HorizontalPodAutoscaler", Groups: []string{"autoscaling"}, Resource: "horizontalpodautoscalers", Namespaced: true, Object: &autoscalingv1.HorizontalPodAutoscaler{}},
+	{Name: "PodDisruptionBudget", Groups: []string{"policy"}, Resource: "poddisruptionbudgets", Namespaced: true, Object: &policyv1beta1.PodDisruptionBudget{}},
+	{Name: "Role", Groups: []string{"rbac.authorization.k8s.io"}, Resource: "roles", Namespaced: true, Object: &rbacv1.Role{}},
+	{Name: "RoleBinding", Groups: []string{"rbac.authorization.k8s.io"}, Resource: "rolebindings", Namespaced: true, Object: &rbacv1.RoleBinding{}},
+	{Name: "ClusterRole", Groups: []string{"rbac.authorization.k8s.io"}, Resource: "clusterroles", Object: &rbacv1.ClusterRole{}},
+	{Name: "ClusterRoleBinding", Groups: []string{"rbac.authorization.k8s.io"}, Resource: "clusterrolebindings", Object: &rbacv1.ClusterRoleBinding{}},
+	{Name: "StorageClass", Groups: []string{"storage.k8s.io"}, Resource: "storageclasses", Object: &storagev1.StorageClass{}},
+	{Name: "PriorityClass", Groups: []string{"scheduling.k8s.io"}, Resource: "priorityclasses", Object: &schedulingv1.PriorityClass{}},
+}
+
+var (
+	mu     sync.RWMutex
+	byName = func() map[string]Kind {
+		m := make(map[string]Kind, len(builtin))
+		for _, k := range builtin {
+			m[k.Name] = k
+		}
+		return m
+	}()
+)
+
+//Register adds kinds that are not built-in, like the ones served by CRDs or aggregated APIs.
+//A kind already known has its groups merged.
+func Register(ks ...Kind) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, k := range ks {
+		current, ok := byName[k.Name]
+		if !ok {
+			byName[k.Name] = k
+			continue
+		}
+		for _, g := range k.Groups {
+			found := false
+			for _, cg := range current.Groups {
+				found = found || cg == g
+			}
+			if !found {
+				current.Groups = append(current.Groups[:len(current.Groups):len(current.Groups)], g)
+			}
+		}
+		byName[k.Name] = current
+	}
+}
+
+//Lookup returns the kind called name
+func Lookup(name string) (Kind, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	k, ok := byName[name]
+	return k, ok
+}
+
+//Names returns the names of every known kind, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
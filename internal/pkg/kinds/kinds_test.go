@@ -0,0 +1,84 @@
+package kinds
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+const certManagerDiscovery = `{
+  "kind": "APIResourceList",
+  "apiVersion": "v1",
+  "groupVersion": "cert-manager.io/v1",
+  "resources": [
+    {"name": "certificates", "singularName": "", "namespaced": true, "kind": "Certificate", "verbs": ["get"]},
+    {"name": "certificates/status", "singularName": "", "namespaced": true, "kind": "Certificate", "verbs": ["get"]},
+    {"name": "clusterissuers", "singularName": "", "namespaced": false, "kind": "ClusterIssuer", "verbs": ["get"]}
+  ]
+}`
+
+func writeFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "aegir-kinds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "discovery")
+	ioutil.WriteFile(path, []byte(content), 0600)
+	return path
+}
+
+func TestLookupBuiltin(t *testing.T) {
+	k, ok := Lookup("Deployment")
+	if !ok {
+		t.Fatal("expected Deployment to be a built-in kind")
+	}
+	assert.DeepEqual(t, k.Groups, []string{"apps", "extensions"})
+	if _, ok := Lookup("Deploymnet"); ok {
+		t.Error("expected Deploymnet to be unknown")
+	}
+}
+
+func TestLoadDiscovery(t *testing.T) {
+	ks, err := LoadDiscovery(writeFile(t, certManagerDiscovery))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Kind{
+		{Name: "Certificate", Groups: []string{"cert-manager.io"}, Resource: "certificates", Namespaced: true},
+		{Name: "ClusterIssuer", Groups: []string{"cert-manager.io"}, Resource: "clusterissuers"},
+	}
+	assert.DeepEqual(t, ks, expected)
+
+	ks, err = LoadDiscovery(writeFile(t, "["+certManagerDiscovery+"]"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, len(ks), 2)
+}
+
+func TestRegister(t *testing.T) {
+	Register(Kind{Name: "Widget", Groups: []string{"example.com"}, Resource: "widgets", Namespaced: true})
+	if _, ok := Lookup("Widget"); !ok {
+		t.Error("expected Widget to be registered")
+	}
+	Register(Kind{Name: "Widget", Groups: []string{"example.org"}, Resource: "widgets", Namespaced: true})
+	k, _ := Lookup("Widget")
+	assert.DeepEqual(t, k.Groups, []string{"example.com", "example.org"})
+}
+
+func TestPodSpecPath(t *testing.T) {
+	path, ok := PodSpecPath("CronJob")
+	assert.Assert(t, ok)
+	assert.Equal(t, path, "spec.jobTemplate.spec.template.spec")
+	path, _ = PodMetadataPath("Deployment")
+	assert.Equal(t, path, "spec.template.metadata")
+	path, _ = PodMetadataPath("Pod")
+	assert.Equal(t, path, "metadata")
+	_, ok = PodSpecPath("Service")
+	assert.Assert(t, !ok)
+	assert.Equal(t, len(PodKinds()), 8)
+}
@@ -0,0 +1,43 @@
+package kinds
+
+import (
+	"sort"
+	"strings"
+)
+
+//podSpecs holds where the pod spec of the kinds that create Pods is, relative to the object
+var podSpecs = map[string]string{
+	"Pod":                   "spec",
+	"ReplicationController": "spec.template.spec",
+	"ReplicaSet":            "spec.template.spec",
+	"Deployment":            "spec.template.spec",
+	"StatefulSet":           "spec.template.spec",
+	"DaemonSet":             "spec.template.spec",
+	"Job":                   "spec.template.spec",
+	"CronJob":               "spec.jobTemplate.spec.template.spec",
+}
+
+//PodSpecPath returns the path of the pod spec of a kind, like spec.template.spec for a Deployment
+func PodSpecPath(kind string) (string, bool) {
+	path, ok := podSpecs[kind]
+	return path, ok
+}
+
+//PodMetadataPath returns the path of the metadata of the Pods created by a kind, like spec.template.metadata for a Deployment
+func PodMetadataPath(kind string) (string, bool) {
+	path, ok := podSpecs[kind]
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(path, "spec") + "metadata", true
+}
+
+//PodKinds returns the kinds that hold a pod spec, sorted by name
+func PodKinds() []string {
+	names := make([]string, 0, len(podSpecs))
+	for name := range podSpecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
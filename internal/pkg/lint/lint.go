@@ -0,0 +1,234 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grupozap/aegir/internal/pkg/kinds"
+	"github.com/grupozap/aegir/internal/pkg/messages"
+	"github.com/grupozap/aegir/internal/pkg/rules"
+	"github.com/grupozap/aegir/internal/pkg/schema"
+	"github.com/grupozap/aegir/internal/pkg/utils"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+//Diagnostic describes a problem that prevents a rule, or one of its definitions, from ever firing.
+//Diagnostics without a rule are about the whole rules file.
+type Diagnostic struct {
+	Rule    string
+	Field   string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	if d.Rule == "" {
+		return d.Message
+	}
+	if d.Field == "" {
+		return fmt.Sprintf("rule %s: %s", d.Rule, d.Message)
+	}
+	return fmt.Sprintf("rule %s, field %s: %s", d.Rule, d.Field, d.Message)
+}
+
+//Lint checks every rule in rl against the known kinds and their schemas
+func Lint(rl *rules.RulesList) []Diagnostic {
+	var diagnostics []Diagnostic
+	if _, err := messages.NewCatalogue(rl.Language, nil); err != nil {
+		diagnostics = append(diagnostics, Diagnostic{Message: err.Error()})
+	}
+	for _, rule := range rl.Rules {
+		diagnostics = append(diagnostics, lintRule(rule)...)
+	}
+	now := time.Now()
+	for _, e := range rl.Exemptions {
+		diagnostics = append(diagnostics, lintExemption(rl, e, now)...)
+	}
+	return diagnostics
+}
+
+//lintExemption reports exemptions that are invalid, expired or that don't apply to any rule
+func lintExemption(rl *rules.RulesList, e *rules.Exemption, now time.Time) []Diagnostic {
+	if err := e.Validate(); err != nil {
+		return []Diagnostic{{Rule: e.Rule, Message: err.Error()}}
+	}
+	if e.Expired(now) {
+		return []Diagnostic{{Rule: e.Rule, Message: fmt.Sprintf("%s expired at %s, it is ignored", e, e.ExpiresAt.Format(time.RFC3339))}}
+	}
+	for _, rule := range rl.Rules {
+		if utils.MatchGlob(e.Rule, rule.Name) {
+			return nil
+		}
+	}
+	return []Diagnostic{{Rule: e.Rule, Message: fmt.Sprintf("%s doesn't apply to any rule", e)}}
+}
+
+func lintRule(rule *rules.Rule) []Diagnostic {
+	var diagnostics []Diagnostic
+	report := func(field, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{Rule: rule.Name, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	//Fields of PodSpec rules are checked against the pod spec of a Pod
+	podSpec := rule.ResourceType == rules.ResourceTypePodSpec
+	kind, known := kinds.Lookup(rule.ResourceType)
+	if podSpec {
+		kind, known = kinds.Lookup("Pod")
+	}
+	switch {
+	case rule.ResourceType == "":
+		report("", "resource_type is required")
+	case !known:
+		msg := fmt.Sprintf("unknown resource_type %q", rule.ResourceType)
+		if suggestion, ok := utils.Closest(rule.ResourceType, kinds.Names()); ok {
+			msg += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		report("", "%s", msg)
+	}
+
+	switch {
+	case rule.Namespace == "":
+		report("", "namespace is required, use \"*\" to apply the rule to every namespace")
+	case rule.Namespace == "*":
+	case len(validation.IsDNS1123Label(rule.Namespace)) > 0:
+		report("", "namespace %q is not a valid namespace name", rule.Namespace)
+	case known && !kind.Namespaced && kind.Name != "Namespace":
+		report("", "%s is not namespaced, its requests never match namespace %q, use \"*\"", kind.Name, rule.Namespace)
+	}
+
+	if len(rule.RulesDefinitions) == 0 {
+		report("", "rules_definitions is empty")
+	}
+
+	if err := rule.ValidateOperations(); err != nil {
+		report("", "%s", err)
+	}
+
+	for _, sa := range rule.ExcludeServiceAccounts {
+		if !strings.Contains(sa, ":") {
+			report("", "exclude_service_accounts %q never matches, use namespace:name like \"%s:*\"", sa, sa)
+		}
+	}
+
+	var roots []*schema.Node
+	if known {
+		roots = schema.ForKind(kind.Name)
+	}
+	for _, ruledef := range rule.RulesDefinitions {
+		if ruledef.Field == "" && ruledef.UsesLIVR() {
+			report("", "a rule definition has no field")
+			continue
+		}
+		if err := ruledef.Compile(); err != nil {
+			report(ruledef.Field, "%s", err)
+			continue
+		}
+		spec, hasPodSpec := kinds.PodSpecPath(kind.Name)
+		if ruledef.Scope == rules.ScopeContainer && known && !hasPodSpec {
+			report(ruledef.Field, "scope %q needs a resource_type holding a pod spec, like %s or %s", rules.ScopeContainer, rules.ResourceTypePodSpec, strings.Join(kinds.PodKinds(), ", "))
+			continue
+		}
+		if ruledef.Field == "" {
+			continue
+		}
+		field := ruledef.Field
+		if podSpec {
+			ruledef = ruledef.InPodSpec("Pod")
+		}
+		path, _ := ruledef.Path()
+		if ruledef.Scope == rules.ScopeContainer {
+			//Fields of container scoped definitions are relative to each container, they are checked against the first list
+			path = spec + "." + rules.ContainerLists[0] + ".#." + path
+		}
+		nodes, err := resolve(roots, path)
+		if err != nil {
+			report(field, "%s", explain(err, kind.Name))
+		}
+		for _, name := range sortedKeys(ruledef.Bind) {
+			var elements []*schema.Node
+			for _, n := range nodes {
+				if n.Items != nil {
+					n = n.Items
+				}
+				elements = append(elements, n)
+			}
+			if _, err := resolve(elements, ruledef.Bind[name]); err != nil {
+				report(field, "bind %q: %s", name, explain(err, kind.Name+" "+ruledef.Field))
+			}
+		}
+		if !ruledef.UsesLIVR() {
+			continue
+		}
+		if len(ruledef.Bind) > 0 {
+			for _, key := range sortedKeys(ruledef.LivrRule.RuleObj) {
+				if _, ok := ruledef.Bind[key]; !ok {
+					report(field, "livr_rule.rule key %q is not bound", key)
+				}
+			}
+			continue
+		}
+		lastField := utils.GetLastField(ruledef.Field)
+		if _, ok := ruledef.LivrRule.RuleObj[lastField]; !ok {
+			report(field, "livr_rule.rule must be keyed by the last field of the path, %q", lastField)
+		}
+	}
+	return diagnostics
+}
+
+//resolve checks path against every schema of a kind, a path is valid if it exists in any of them.
+//It returns the nodes the path points to.
+func resolve(roots []*schema.Node, path string) ([]*schema.Node, error) {
+	var nodes []*schema.Node
+	var first error
+	for _, root := range roots {
+		n, err := root.Resolve(path)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) > 0 {
+		return nodes, nil
+	}
+	return nil, first
+}
+
+//explain describes a path error in what, suggesting the closest existing path
+func explain(err error, what string) string {
+	msg := fmt.Sprintf("%s of %s", err, what)
+	if pe, ok := err.(*schema.PathError); ok {
+		if suggestion, ok := pe.Suggestion(); ok {
+			msg += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+	}
+	return msg
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch v := m.(type) {
+	case map[string]string:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//Format renders diagnostics one per line
+func Format(diagnostics []Diagnostic) string {
+	lines := make([]string, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,105 @@
+package lint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grupozap/aegir/internal/pkg/rules"
+	"gotest.tools/assert"
+)
+
+func livrRule(key string) rules.RuleObject {
+	return rules.RuleObject{RuleObj: map[string]interface{}{key: "required"}}
+}
+
+func TestLintValidRules(t *testing.T) {
+	rl := rules.RulesLoader("../../../etc/rules.yaml")
+	assert.DeepEqual(t, Lint(&rl), []Diagnostic(nil))
+}
+
+func TestLintReportsUnknownLanguage(t *testing.T) {
+	rl := &rules.RulesList{Language: "fr"}
+	diagnostics := Lint(rl)
+	assert.DeepEqual(t, diagnostics, []Diagnostic{{Message: `unknown language "fr", use one of en, pt`}})
+	assert.Equal(t, Format(diagnostics), `unknown language "fr", use one of en, pt`)
+}
+
+func TestLintReportsExemptions(t *testing.T) {
+	future, past := time.Now().Add(24*time.Hour), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	exemption := func(rule, namespace string, expiresAt time.Time) *rules.Exemption {
+		return &rules.Exemption{Rule: rule, Namespace: namespace, Reason: "migration", Owner: "team-a", ExpiresAt: expiresAt}
+	}
+	rl := &rules.RulesList{
+		Rules: []*rules.Rule{{Name: "no_latest", Namespace: "*", ResourceType: "Pod", RulesDefinitions: []rules.RuleDefinition{{Field: "spec.containers.#.image", LivrRule: livrRule("image")}}}},
+		Exemptions: []*rules.Exemption{
+			exemption("no_latest", "team-a", future),
+			exemption("no_*", "*", future),
+			exemption("no_latest", "team-b", past),
+			exemption("no_latset", "team-a", future),
+			exemption("no_latest", "", future),
+		},
+	}
+	assert.DeepEqual(t, Lint(rl), []Diagnostic{
+		{Rule: "no_latest", Message: "exemption of rule no_latest for team-b/* owned by team-a expired at 2020-01-01T00:00:00Z, it is ignored"},
+		{Rule: "no_latset", Message: "exemption of rule no_latset for team-a/* owned by team-a doesn't apply to any rule"},
+		{Rule: "no_latest", Message: `exemption of rule no_latest has no namespace, use "*" to apply it to every namespace`},
+	})
+}
+
+func TestLintReportsRulesThatNeverFire(t *testing.T) {
+	rl := &rules.RulesList{Rules: []*rules.Rule{
+		{Name: "typo", Namespace: "*", ResourceType: "Deploymnet", RulesDefinitions: []rules.RuleDefinition{{Field: "metadata.labels", LivrRule: livrRule("labels")}}},
+		{Name: "no_namespace", ResourceType: "Pod", RulesDefinitions: []rules.RuleDefinition{{Field: "metadata.labels", LivrRule: livrRule("labels")}}},
+		{Name: "bad_namespace", Namespace: "Team_A", ResourceType: "Pod", RulesDefinitions: []rules.RuleDefinition{{Field: "metadata.labels", LivrRule: livrRule("labels")}}},
+		{Name: "cluster_scoped", Namespace: "team-a", ResourceType: "ClusterRole", RulesDefinitions: []rules.RuleDefinition{{Field: "metadata.labels", LivrRule: livrRule("labels")}}},
+		{Name: "no_definitions", Namespace: "*", ResourceType: "Pod"},
+		{Name: "bad_operation", Namespace: "*", ResourceType: "Pod", Operations: []string{"CREATE", "PATCH"}, RulesDefinitions: []rules.RuleDefinition{{Field: "metadata.labels", LivrRule: livrRule("labels")}}},
+		{Name: "bad_service_account", Namespace: "*", ResourceType: "Pod", ExcludeServiceAccounts: []string{"kube-system:*", "kube-system"}, RulesDefinitions: []rules.RuleDefinition{{Field: "metadata.labels", LivrRule: livrRule("labels")}}},
+		{Name: "bad_field", Namespace: "*", ResourceType: "Deployment", RulesDefinitions: []rules.RuleDefinition{{Field: "spec.containers.#.name", LivrRule: livrRule("name")}}},
+		{Name: "typo_field", Namespace: "*", ResourceType: "Deployment", RulesDefinitions: []rules.RuleDefinition{{Field: "spec.template.spec.container.#.name", LivrRule: livrRule("name")}}},
+		{Name: "bad_syntax", Namespace: "*", ResourceType: "Deployment", RulesDefinitions: []rules.RuleDefinition{{Field: "spec.replicas", FieldSyntax: "jmespath", LivrRule: livrRule("replicas")}}},
+		{Name: "jsonpath_field", Namespace: "*", ResourceType: "Deployment", RulesDefinitions: []rules.RuleDefinition{{Field: "{.spec.template.spec.containers[*].imag}", FieldSyntax: rules.FieldSyntaxJSONPath, LivrRule: livrRule("imag")}}},
+		{Name: "cel_only", Namespace: "*", ResourceType: "Pod", RulesDefinitions: []rules.RuleDefinition{{CEL: &rules.CELRule{Expression: "!object.spec.hostNetwork || request.namespace == 'kube-system'"}}}},
+		{Name: "bad_cel", Namespace: "*", ResourceType: "Pod", RulesDefinitions: []rules.RuleDefinition{{CEL: &rules.CELRule{Expression: "object.spec.replicas + 1"}}}},
+		{Name: "rego_only", Namespace: "*", ResourceType: "Pod", RulesDefinitions: []rules.RuleDefinition{{Rego: &rules.RegoRule{Module: "package pods\ndeny[msg] { input.request.object.spec.hostPID; msg := \"hostPID\" }"}}}},
+		{Name: "bad_rego", Namespace: "*", ResourceType: "Pod", RulesDefinitions: []rules.RuleDefinition{{Rego: &rules.RegoRule{Module: "package pods\nallow = true"}}}},
+		{Name: "bound", Namespace: "*", ResourceType: "Deployment", RulesDefinitions: []rules.RuleDefinition{{
+			Field:    "spec.template.spec.containers",
+			Bind:     map[string]string{"requests": "resources.requests.cpu", "limits": "resources.limts.cpu"},
+			LivrRule: rules.RuleObject{RuleObj: map[string]interface{}{"requests": "required", "limit": "required"}},
+		}}},
+		{Name: "bad_key", Namespace: "*", ResourceType: "Deployment", RulesDefinitions: []rules.RuleDefinition{{Field: "spec.replicas", LivrRule: livrRule("replica")}}},
+		{Name: "unknown_livr", Namespace: "*", ResourceType: "Deployment", RulesDefinitions: []rules.RuleDefinition{{Field: "spec.replicas", LivrRule: rules.RuleObject{RuleObj: map[string]interface{}{"replicas": "no_such_rule"}}}}},
+		{Name: "pod_spec", Namespace: "team-a", ResourceType: rules.ResourceTypePodSpec, RulesDefinitions: []rules.RuleDefinition{
+			{Field: "containers.#.image", LivrRule: livrRule("image")},
+			{Field: "containers[*].imag", FieldSyntax: rules.FieldSyntaxJSONPath, LivrRule: livrRule("imag")},
+			{Field: "securityContext.privileged", Scope: rules.ScopeContainer, LivrRule: livrRule("privileged")},
+			{Field: "securityContext.privilegd", Scope: rules.ScopeContainer, LivrRule: livrRule("privilegd")},
+		}},
+		{Name: "containers", Namespace: "*", ResourceType: "StatefulSet", RulesDefinitions: []rules.RuleDefinition{{Field: "image", Scope: rules.ScopeContainer, LivrRule: livrRule("image")}}},
+		{Name: "no_containers", Namespace: "*", ResourceType: "ConfigMap", RulesDefinitions: []rules.RuleDefinition{{Field: "image", Scope: rules.ScopeContainer, LivrRule: livrRule("image")}}},
+	}}
+	expected := []Diagnostic{
+		{Rule: "typo", Message: `unknown resource_type "Deploymnet", did you mean "Deployment"?`},
+		{Rule: "no_namespace", Message: `namespace is required, use "*" to apply the rule to every namespace`},
+		{Rule: "bad_namespace", Message: `namespace "Team_A" is not a valid namespace name`},
+		{Rule: "cluster_scoped", Message: `ClusterRole is not namespaced, its requests never match namespace "team-a", use "*"`},
+		{Rule: "no_definitions", Message: "rules_definitions is empty"},
+		{Rule: "bad_operation", Message: `unknown operation "PATCH", use CREATE, UPDATE, DELETE, CONNECT or "*"`},
+		{Rule: "bad_service_account", Message: `exclude_service_accounts "kube-system" never matches, use namespace:name like "kube-system:*"`},
+		{Rule: "bad_field", Field: "spec.containers.#.name", Message: `field "containers" does not exist in "spec" of Deployment`},
+		{Rule: "typo_field", Field: "spec.template.spec.container.#.name", Message: `field "container" does not exist in "spec.template.spec" of Deployment, did you mean "spec.template.spec.containers.#.name"?`},
+		{Rule: "bad_syntax", Field: "spec.replicas", Message: `unknown field_syntax "jmespath", use "gjson" or "jsonpath"`},
+		{Rule: "jsonpath_field", Field: "{.spec.template.spec.containers[*].imag}", Message: `field "imag" does not exist in "spec.template.spec.containers.#" of Deployment, did you mean "spec.template.spec.containers.#.image"?`},
+		{Rule: "bad_cel", Message: "cel.expression must return a bool"},
+		{Rule: "bad_rego", Message: "rego.module must define deny or violation"},
+		{Rule: "bound", Field: "spec.template.spec.containers", Message: `bind "limits": field "limts" does not exist in "resources" of Deployment spec.template.spec.containers, did you mean "resources.limits.cpu"?`},
+		{Rule: "bound", Field: "spec.template.spec.containers", Message: `livr_rule.rule key "limit" is not bound`},
+		{Rule: "bad_key", Field: "spec.replicas", Message: `livr_rule.rule must be keyed by the last field of the path, "replicas"`},
+		{Rule: "unknown_livr", Field: "spec.replicas", Message: "invalid livr_rule: Rule no_such_rule not registerd"},
+		{Rule: "pod_spec", Field: "containers[*].imag", Message: `field "imag" does not exist in "spec.containers.#" of Pod, did you mean "spec.containers.#.image"?`},
+		{Rule: "pod_spec", Field: "securityContext.privilegd", Message: `field "privilegd" does not exist in "spec.containers.#.securityContext" of Pod, did you mean "spec.containers.#.securityContext.privileged"?`},
+		{Rule: "no_containers", Field: "image", Message: `scope "container" needs a resource_type holding a pod spec, like PodSpec or CronJob, DaemonSet, Deployment, Job, Pod, ReplicaSet, ReplicationController, StatefulSet`},
+	}
+	assert.DeepEqual(t, Lint(rl), expected)
+}
@@ -0,0 +1,218 @@
+package messages
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	//LanguageEnglish is the language of error messages when none is set
+	LanguageEnglish = "en"
+	//LanguagePortuguese translates error messages to Portuguese
+	LanguagePortuguese = "pt"
+)
+
+//catalogues describes, in each language, the error codes of the LIVR rules and of the rules in pkg/customrules
+var catalogues = map[string]map[string]string{
+	LanguageEnglish: {
+		"REQUIRED":             "is required",
+		"CANNOT_BE_EMPTY":      "can't be empty",
+		"NOT_ALLOWED_VALUE":    "has a value that is not allowed",
+		"FORMAT_ERROR":         "has the wrong type",
+		"WRONG_FORMAT":         "doesn't match the expected format",
+		"TOO_LONG":             "is too long",
+		"TOO_SHORT":            "is too short",
+		"TOO_HIGH":             "is too high",
+		"TOO_LOW":              "is too low",
+		"NOT_NUMBER":           "is not a number",
+		"NOT_INTEGER":          "is not an integer",
+		"NOT_POSITIVE_INTEGER": "is not a positive integer",
+		"NOT_DECIMAL":          "is not a decimal number",
+		"NOT_POSITIVE_DECIMAL": "is not a positive decimal number",
+		"WRONG_EMAIL":          "is not an email address",
+		"WRONG_URL":            "is not a URL",
+		"WRONG_DATE":           "is not a date",
+		"FIELDS_NOT_EQUAL":     "is not equal to the field it is compared with",
+		"WRONG_ARGUMENT":       "can't be checked, the rule has an invalid argument",
+		"REGISTRY_NOT_ALLOWED": "uses a registry that is not allowed",
+		"DIGEST_REQUIRED":      "must reference the image by digest",
+		"TAG_NOT_ALLOWED":      "uses a tag that is not allowed",
+		"NOT_SEMVER":           "is not tagged with a semantic version",
+		"VERSION_NOT_ALLOWED":  "is tagged with a version that is not allowed",
+	},
+	LanguagePortuguese: {
+		"REQUIRED":             "é obrigatório",
+		"CANNOT_BE_EMPTY":      "não pode ser vazio",
+		"NOT_ALLOWED_VALUE":    "tem um valor que não é permitido",
+		"FORMAT_ERROR":         "tem o tipo errado",
+		"WRONG_FORMAT":         "não está no formato esperado",
+		"TOO_LONG":             "é longo demais",
+		"TOO_SHORT":            "é curto demais",
+		"TOO_HIGH":             "é alto demais",
+		"TOO_LOW":              "é baixo demais",
+		"NOT_NUMBER":           "não é um número",
+		"NOT_INTEGER":          "não é um número inteiro",
+		"NOT_POSITIVE_INTEGER": "não é um número inteiro positivo",
+		"NOT_DECIMAL":          "não é um número decimal",
+		"NOT_POSITIVE_DECIMAL": "não é um número decimal positivo",
+		"WRONG_EMAIL":          "não é um endereço de email",
+		"WRONG_URL":            "não é uma URL",
+		"WRONG_DATE":           "não é uma data",
+		"FIELDS_NOT_EQUAL":     "não é igual ao campo com o qual é comparado",
+		"WRONG_ARGUMENT":       "não pode ser verificado, a regra tem um argumento inválido",
+		"REGISTRY_NOT_ALLOWED": "usa um registry que não é permitido",
+		"DIGEST_REQUIRED":      "deve referenciar a imagem pelo digest",
+		"TAG_NOT_ALLOWED":      "usa uma tag que não é permitida",
+		"NOT_SEMVER":           "não tem uma tag de versão semântica",
+		"VERSION_NOT_ALLOWED":  "tem uma tag de versão que não é permitida",
+	},
+}
+
+//unknownCodes describes, in each language, a code missing from the catalogue
+var unknownCodes = map[string]string{
+	LanguageEnglish:    "is invalid (%s)",
+	LanguagePortuguese: "é inválido (%s)",
+}
+
+//Languages returns the languages error messages can be translated to
+func Languages() []string {
+	ls := make([]string, 0, len(catalogues))
+	for l := range catalogues {
+		ls = append(ls, l)
+	}
+	sort.Strings(ls)
+	return ls
+}
+
+//Catalogue translates error codes into sentences of a language, English when Language is empty.
+//Overrides replace the sentence of a code, or of a code for a single field when keyed like image.TAG_NOT_ALLOWED
+//or ports.protocol.NOT_ALLOWED_VALUE, indexes of list_of errors are left out.
+type Catalogue struct {
+	Language  string
+	Overrides map[string]string
+}
+
+//NewCatalogue returns the catalogue of a language, like pt or pt-BR, with overrides
+func NewCatalogue(language string, overrides map[string]string) (Catalogue, error) {
+	l := strings.ToLower(language)
+	if i := strings.IndexAny(l, "-_"); i >= 0 {
+		l = l[:i]
+	}
+	if l == "" {
+		l = LanguageEnglish
+	}
+	if _, ok := catalogues[l]; !ok {
+		return Catalogue{}, fmt.Errorf("unknown language %q, use one of %s", language, strings.Join(Languages(), ", "))
+	}
+	return Catalogue{Language: l, Overrides: overrides}, nil
+}
+
+//Sentence describes code, the error of field
+func (c Catalogue) Sentence(field, code string) string {
+	if field != "" {
+		if s, ok := c.Overrides[field+"."+code]; ok {
+			return s
+		}
+		if s, ok := c.Overrides[withoutIndexes(field)+"."+code]; ok {
+			return s
+		}
+	}
+	if s, ok := c.Overrides[code]; ok {
+		return s
+	}
+	language := c.Language
+	if _, ok := catalogues[language]; !ok {
+		language = LanguageEnglish
+	}
+	if s, ok := catalogues[language][code]; ok {
+		return s
+	}
+	return fmt.Sprintf(unknownCodes[language], code)
+}
+
+//withoutIndexes removes the indexes of list_of errors from a field, ports[0].protocol becomes ports.protocol
+func withoutIndexes(field string) string {
+	sb := strings.Builder{}
+	depth := 0
+	for _, c := range field {
+		switch {
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+//Codes converts the errors of a LIVR validator into their codes,
+//keeping the maps of nested_object and the lists of list_of and list_of_objects
+func Codes(errs interface{}) interface{} {
+	switch e := errs.(type) {
+	case nil:
+		return nil
+	case error:
+		return e.Error()
+	case map[string]interface{}:
+		codes := make(map[string]interface{}, len(e))
+		for k, v := range e {
+			codes[k] = Codes(v)
+		}
+		return codes
+	case []interface{}:
+		codes := make([]interface{}, len(e))
+		for i, v := range e {
+			codes[i] = Codes(v)
+		}
+		return codes
+	default:
+		return fmt.Sprint(e)
+	}
+}
+
+//Explain translates error codes into English sentences, see Catalogue.Explain
+func Explain(codes interface{}) string {
+	return Catalogue{}.Explain(codes)
+}
+
+//Explain translates error codes into a sentence per field, like "image uses a tag that is not allowed".
+//Fields of nested_object errors are joined by dots and elements of list_of errors are indexed, like ports[1].protocol.
+func (c Catalogue) Explain(codes interface{}) string {
+	return strings.Join(c.explain("", codes), "; ")
+}
+
+func (c Catalogue) explain(field string, codes interface{}) []string {
+	switch v := codes.(type) {
+	case string:
+		sentence := c.Sentence(field, v)
+		if field == "" {
+			return []string{sentence}
+		}
+		return []string{field + " " + sentence}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var lines []string
+		for _, k := range keys {
+			name := k
+			if field != "" {
+				name = field + "." + k
+			}
+			lines = append(lines, c.explain(name, v[k])...)
+		}
+		return lines
+	case []interface{}:
+		var lines []string
+		for i, e := range v {
+			lines = append(lines, c.explain(fmt.Sprintf("%s[%d]", field, i), e)...)
+		}
+		return lines
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package messages
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/grupozap/aegir/pkg/customrules"
+	"gotest.tools/assert"
+)
+
+func TestExplain(t *testing.T) {
+	errs := map[string]interface{}{
+		"image":    errors.New("TAG_NOT_ALLOWED"),
+		"replicas": errors.New("TOO_HIGH"),
+		"resources": map[string]interface{}{
+			"limits": errors.New("REQUIRED"),
+		},
+		"ports":  []interface{}{nil, errors.New("NOT_POSITIVE_INTEGER")},
+		"labels": errors.New("NOT_A_KNOWN_CODE"),
+	}
+	codes := Codes(errs)
+	assert.DeepEqual(t, codes, map[string]interface{}{
+		"image":     "TAG_NOT_ALLOWED",
+		"replicas":  "TOO_HIGH",
+		"resources": map[string]interface{}{"limits": "REQUIRED"},
+		"ports":     []interface{}{nil, "NOT_POSITIVE_INTEGER"},
+		"labels":    "NOT_A_KNOWN_CODE",
+	})
+	assert.Equal(t, Explain(codes), "image uses a tag that is not allowed; labels is invalid (NOT_A_KNOWN_CODE); ports[1] is not a positive integer; replicas is too high; resources.limits is required")
+	assert.Equal(t, Explain("REQUIRED"), "is required")
+}
+
+func TestCatalogue(t *testing.T) {
+	codes := map[string]interface{}{
+		"cpu":   "REQUIRED",
+		"ports": []interface{}{map[string]interface{}{"protocol": "NOT_ALLOWED_VALUE"}},
+		"image": "UNKNOWN",
+	}
+	pt, err := NewCatalogue("pt_BR", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, pt.Language, LanguagePortuguese)
+	assert.Equal(t, pt.Explain(codes), "cpu é obrigatório; image é inválido (UNKNOWN); ports[0].protocol tem um valor que não é permitido")
+
+	overridden, err := NewCatalogue("", map[string]string{
+		"REQUIRED":                         "must be set, see https://runbooks.example.com/resources",
+		"ports.protocol.NOT_ALLOWED_VALUE": "must be TCP",
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, overridden.Explain(codes), "cpu must be set, see https://runbooks.example.com/resources; image is invalid (UNKNOWN); ports[0].protocol must be TCP")
+
+	_, err = NewCatalogue("fr", nil)
+	assert.ErrorContains(t, err, `unknown language "fr", use one of en, pt`)
+}
+
+//TestCataloguesCoverCustomRules checks that every error code of the rules in pkg/customrules is described in every language
+func TestCataloguesCoverCustomRules(t *testing.T) {
+	for _, language := range Languages() {
+		for _, code := range customrules.ErrorCodes() {
+			_, ok := catalogues[language][code]
+			assert.Assert(t, ok, "%s is missing from the %s catalogue", code, language)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package messages
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+)
+
+const (
+	//DefaultMessageTemplate renders a violation in the admission response
+	DefaultMessageTemplate = `rule name: '{{.RuleName}}', field: '{{or .Path .Field}}',{{if .Container}} container: '{{.Container}}' ({{.ContainerList}}),{{else if .Element}} element: '{{.Element}}',{{end}} description: '{{.Description}}', message: {{.Error}}`
+	//DefaultNotificationTemplate renders a violation in a notification
+	DefaultNotificationTemplate = "Rule name: *{{.RuleName}}*\n Rule Description: *{{.Description}}*\n"
+)
+
+var (
+	defaultMessage      = template.Must(Parse("default_message", DefaultMessageTemplate))
+	defaultNotification = template.Must(Parse("default_notification", DefaultNotificationTemplate))
+)
+
+//Data holds everything a message template has access to
+type Data struct {
+	RuleName    string
+	Description string
+	Field       string
+	Value       interface{}
+	Error       string
+	Kind        string
+	Name        string
+	Namespace   string
+	User        string
+	Operation   string
+	Cluster     string
+	RunbookURL  string
+
+	//Path is where the violating value is, like spec.containers[2].name, and Element the name of the element holding it
+	Path    string
+	Element string
+
+	//Container and ContainerList name the container that violated a definition scoped to containers
+	Container     string
+	ContainerList string
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+//Parse compiles a message template with the helper functions available to every template
+func Parse(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Parse(text)
+}
+
+//Render executes t with d, falling back to fallback when t is nil
+func Render(t, fallback *template.Template, d Data) (string, error) {
+	if t == nil {
+		t = fallback
+	}
+	sb := strings.Builder{}
+	if err := t.Execute(&sb, d); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+//RenderMessage renders d with t or with DefaultMessageTemplate when t is nil
+func RenderMessage(t *template.Template, d Data) (string, error) {
+	return Render(t, defaultMessage, d)
+}
+
+//RenderNotification renders d with t or with DefaultNotificationTemplate when t is nil
+func RenderNotification(t *template.Template, d Data) (string, error) {
+	return Render(t, defaultNotification, d)
+}
@@ -0,0 +1,92 @@
+package messages
+
+import (
+	"testing"
+)
+
+var testData = Data{
+	RuleName:    "image_tag",
+	Description: "latest tag is not allowed",
+	Field:       "spec.containers.#.image",
+	Value:       "nginx:latest",
+	Error:       "NOT_ALLOWED_VALUE",
+	Kind:        "Pod",
+	Name:        "nginx",
+	Namespace:   "default",
+	User:        "jane",
+	Operation:   "CREATE",
+	RunbookURL:  "https://runbooks.example.com/image_tag",
+}
+
+func TestRenderMessageDefault(t *testing.T) {
+	expected := `rule name: 'image_tag', field: 'spec.containers.#.image', description: 'latest tag is not allowed', message: NOT_ALLOWED_VALUE`
+	result, err := RenderMessage(nil, testData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != expected {
+		t.Errorf("expected '%s' but got '%s'", expected, result)
+	}
+}
+
+func TestRenderMessageDefaultWithContainer(t *testing.T) {
+	d := testData
+	d.Field, d.Container, d.ContainerList = "spec.initContainers.0.image", "setup", "initContainers"
+	expected := `rule name: 'image_tag', field: 'spec.initContainers.0.image', container: 'setup' (initContainers), description: 'latest tag is not allowed', message: NOT_ALLOWED_VALUE`
+	result, err := RenderMessage(nil, d)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != expected {
+		t.Errorf("expected '%s' but got '%s'", expected, result)
+	}
+}
+
+func TestRenderMessageDefaultWithPath(t *testing.T) {
+	d := testData
+	d.Path, d.Element = "spec.containers[1].image", "nginx"
+	expected := `rule name: 'image_tag', field: 'spec.containers[1].image', element: 'nginx', description: 'latest tag is not allowed', message: NOT_ALLOWED_VALUE`
+	result, err := RenderMessage(nil, d)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != expected {
+		t.Errorf("expected '%s' but got '%s'", expected, result)
+	}
+}
+
+func TestRenderNotificationDefault(t *testing.T) {
+	expected := "Rule name: *image_tag*\n Rule Description: *latest tag is not allowed*\n"
+	result, err := RenderNotification(nil, testData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != expected {
+		t.Errorf("expected '%s' but got '%s'", expected, result)
+	}
+}
+
+func TestRenderCustomTemplate(t *testing.T) {
+	tmpl, err := Parse("custom", `{{.User}} tried to {{lower .Operation}} {{.Kind}} {{.Namespace}}/{{.Name}} with {{.Field}}={{json .Value}}, see {{.RunbookURL}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `jane tried to create Pod default/nginx with spec.containers.#.image="nginx:latest", see https://runbooks.example.com/image_tag`
+	result, err := RenderMessage(tmpl, testData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != expected {
+		t.Errorf("expected '%s' but got '%s'", expected, result)
+	}
+}
+
+func TestRenderUnknownField(t *testing.T) {
+	tmpl, err := Parse("custom", `{{.DoesNotExist}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := RenderMessage(tmpl, testData); err == nil {
+		t.Error("expected an error rendering an unknown field")
+	}
+}
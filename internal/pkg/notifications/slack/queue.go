@@ -0,0 +1,206 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+var (
+	//ErrNotifierClosed is returned when a message is enqueued after the notifier was closed
+	ErrNotifierClosed = errors.New("notifier is closed")
+	//ErrQueueFull is returned when there is no room left in the queue for a new message
+	ErrQueueFull = errors.New("notification queue is full")
+)
+
+//metrics exposes the delivery counters through expvar
+var metrics = expvar.NewMap("notifications")
+
+type sendFunc func(message NotificationMessage, channel string) error
+
+type job struct {
+	message NotificationMessage
+	channel string
+}
+
+//Options configures the size of the worker pool and the retry policy of a Notifier
+type Options struct {
+	Workers     int
+	QueueSize   int
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	//APIURL overrides the Slack API endpoint, mostly useful for testing
+	APIURL string
+}
+
+//DefaultOptions returns the options used when nothing is configured
+func DefaultOptions() Options {
+	return Options{
+		Workers:     4,
+		QueueSize:   100,
+		MaxRetries:  3,
+		BaseBackoff: time.Second,
+		MaxBackoff:  30 * time.Second,
+	}
+}
+
+//Notifier delivers notifications asynchronously using a bounded queue and a fixed pool of workers
+type Notifier struct {
+	opts  Options
+	send  sendFunc
+	queue chan job
+	stop  chan struct{}
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+//NewNotifier creates a Notifier that posts messages to Slack using botToken and starts its workers
+func NewNotifier(botToken string, opts Options) *Notifier {
+	var options []slack.Option
+	if opts.APIURL != "" {
+		options = append(options, slack.OptionAPIURL(opts.APIURL))
+	}
+	return newNotifier(func(message NotificationMessage, channel string) error {
+		return NotifyViolation(message, botToken, channel, options...)
+	}, opts)
+}
+
+func newNotifier(send sendFunc, opts Options) *Notifier {
+	defaults := DefaultOptions()
+	if opts.Workers <= 0 {
+		opts.Workers = defaults.Workers
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaults.QueueSize
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = defaults.BaseBackoff
+	}
+	if opts.MaxBackoff < opts.BaseBackoff {
+		opts.MaxBackoff = opts.BaseBackoff
+	}
+	n := &Notifier{
+		opts:  opts,
+		send:  send,
+		queue: make(chan job, opts.QueueSize),
+		stop:  make(chan struct{}),
+	}
+	n.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go n.worker()
+	}
+	return n
+}
+
+//Enqueue schedules a message for delivery without blocking the caller.
+//Messages are dropped when the queue is full or the notifier is closed.
+func (n *Notifier) Enqueue(message NotificationMessage, channel string) error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		metrics.Add("dropped", 1)
+		return ErrNotifierClosed
+	}
+	select {
+	case n.queue <- job{message: message, channel: channel}:
+		metrics.Add("enqueued", 1)
+		return nil
+	default:
+		metrics.Add("dropped", 1)
+		return ErrQueueFull
+	}
+}
+
+//Close stops accepting new messages and waits for the queued ones to be delivered.
+//If ctx expires first, pending retries are abandoned and ctx's error is returned.
+func (n *Notifier) Close(ctx context.Context) error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	close(n.queue)
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		close(n.stop)
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (n *Notifier) worker() {
+	defer n.wg.Done()
+	for j := range n.queue {
+		n.deliver(j)
+	}
+}
+
+func (n *Notifier) deliver(j job) {
+	for attempt := 0; ; attempt++ {
+		err := n.send(j.message, j.channel)
+		if err == nil {
+			metrics.Add("sent", 1)
+			return
+		}
+		if attempt >= n.opts.MaxRetries || !isRetryable(err) {
+			metrics.Add("failed", 1)
+			log.Printf("Error sending slack notification to channel %s after %d attempt(s): %s\n", j.channel, attempt+1, err)
+			return
+		}
+		metrics.Add("retried", 1)
+		timer := time.NewTimer(n.backoff(attempt, err))
+		select {
+		case <-timer.C:
+		case <-n.stop:
+			timer.Stop()
+			metrics.Add("failed", 1)
+			log.Printf("Giving up slack notification to channel %s on shutdown: %s\n", j.channel, err)
+			return
+		}
+	}
+}
+
+//backoff returns the time to wait before the next attempt, honoring the
+//Retry-After sent by Slack when it is longer than the exponential delay
+func (n *Notifier) backoff(attempt int, err error) time.Duration {
+	d := n.opts.BaseBackoff << uint(attempt)
+	if d <= 0 || d > n.opts.MaxBackoff {
+		d = n.opts.MaxBackoff
+	}
+	var rl *slack.RateLimitedError
+	if errors.As(err, &rl) && rl.RetryAfter > d {
+		d = rl.RetryAfter
+	}
+	return d
+}
+
+func isRetryable(err error) bool {
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
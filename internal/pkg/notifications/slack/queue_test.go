@@ -0,0 +1,149 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+type retryableError struct{}
+
+func (retryableError) Error() string   { return "server error" }
+func (retryableError) Retryable() bool { return true }
+
+func metricValue(name string) int64 {
+	if v, ok := metrics.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func testOptions() Options {
+	return Options{
+		Workers:     2,
+		QueueSize:   10,
+		MaxRetries:  3,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  5 * time.Millisecond,
+	}
+}
+
+func TestNotifierDeliversQueuedMessagesOnClose(t *testing.T) {
+	var delivered int32
+	n := newNotifier(func(NotificationMessage, string) error {
+		time.Sleep(2 * time.Millisecond)
+		atomic.AddInt32(&delivered, 1)
+		return nil
+	}, testOptions())
+	for i := 0; i < 10; i++ {
+		if err := n.Enqueue(NotificationMessage{}, "#channel"); err != nil {
+			t.Fatalf("unexpected error enqueuing message: %v", err)
+		}
+	}
+	if err := n.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error closing notifier: %v", err)
+	}
+	if delivered != 10 {
+		t.Errorf("expected 10 delivered messages but got %d", delivered)
+	}
+	if err := n.Enqueue(NotificationMessage{}, "#channel"); err != ErrNotifierClosed {
+		t.Errorf("expected '%v' but got '%v'", ErrNotifierClosed, err)
+	}
+}
+
+func TestNotifierRetriesRetryableErrors(t *testing.T) {
+	var attempts int32
+	n := newNotifier(func(NotificationMessage, string) error {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return retryableError{}
+		}
+		return nil
+	}, testOptions())
+	sent := metricValue("sent")
+	n.Enqueue(NotificationMessage{}, "#channel")
+	n.Close(context.Background())
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts but got %d", attempts)
+	}
+	if got := metricValue("sent") - sent; got != 1 {
+		t.Errorf("expected 1 sent message but got %d", got)
+	}
+}
+
+func TestNotifierDoesNotRetryPermanentErrors(t *testing.T) {
+	var attempts int32
+	n := newNotifier(func(NotificationMessage, string) error {
+		atomic.AddInt32(&attempts, 1)
+		return errors.New("channel_not_found")
+	}, testOptions())
+	failed := metricValue("failed")
+	n.Enqueue(NotificationMessage{}, "#channel")
+	n.Close(context.Background())
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt but got %d", attempts)
+	}
+	if got := metricValue("failed") - failed; got != 1 {
+		t.Errorf("expected 1 failed message but got %d", got)
+	}
+}
+
+func TestNotifierDropsWhenQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	started := make(chan struct{})
+	opts := testOptions()
+	opts.Workers = 1
+	opts.QueueSize = 1
+	n := newNotifier(func(NotificationMessage, string) error {
+		once.Do(func() { close(started) })
+		<-release
+		return nil
+	}, opts)
+	dropped := metricValue("dropped")
+	n.Enqueue(NotificationMessage{}, "#channel")
+	<-started
+	n.Enqueue(NotificationMessage{}, "#channel")
+	if err := n.Enqueue(NotificationMessage{}, "#channel"); err != ErrQueueFull {
+		t.Errorf("expected '%v' but got '%v'", ErrQueueFull, err)
+	}
+	if got := metricValue("dropped") - dropped; got != 1 {
+		t.Errorf("expected 1 dropped message but got %d", got)
+	}
+	close(release)
+	n.Close(context.Background())
+}
+
+func TestNotifierCloseAbandonsRetriesOnTimeout(t *testing.T) {
+	opts := testOptions()
+	opts.BaseBackoff = time.Hour
+	opts.MaxBackoff = time.Hour
+	n := newNotifier(func(NotificationMessage, string) error {
+		return retryableError{}
+	}, opts)
+	n.Enqueue(NotificationMessage{}, "#channel")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := n.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected '%v' but got '%v'", context.DeadlineExceeded, err)
+	}
+}
+
+func TestBackoffHonorsRateLimit(t *testing.T) {
+	n := &Notifier{opts: testOptions()}
+	if d := n.backoff(1, retryableError{}); d != 2*time.Millisecond {
+		t.Errorf("expected '%s' but got '%s'", 2*time.Millisecond, d)
+	}
+	if d := n.backoff(10, retryableError{}); d != 5*time.Millisecond {
+		t.Errorf("expected '%s' but got '%s'", 5*time.Millisecond, d)
+	}
+	rl := &slack.RateLimitedError{RetryAfter: time.Second}
+	if d := n.backoff(0, rl); d != time.Second {
+		t.Errorf("expected '%s' but got '%s'", time.Second, d)
+	}
+}
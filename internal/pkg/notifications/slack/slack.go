@@ -2,48 +2,129 @@ package notifications
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nlopes/slack"
 )
 
+const (
+	//ColorDeny is used for violations that denied the request
+	ColorDeny = "#FD0D0D"
+	//ColorWarn is used for violations that only produced warnings
+	ColorWarn = "#FFA500"
+)
+
 type NotificationMessage struct {
 	Message           string
 	ResourceType      string
 	ResourceNamespace string
+	ResourceName      string
+	User              string
+	Operation         string
+	Cluster           string
+	Field             string
+	Element           string
+	Container         string
+	Value             string
+	RemediationURL    string
+	EnforcementMode   string
+}
+
+//ColorFor returns the attachment color of an enforcement mode
+func ColorFor(mode string) string {
+	if mode == "warn" {
+		return ColorWarn
+	}
+	return ColorDeny
 }
 
-func NotifyViolation(message NotificationMessage, botToken, channelString, color string) {
+//FormatValue renders an offending value to be displayed in a notification
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+func field(title, value string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n%s", title, value), false, false)
+}
+
+//blocks builds the Block Kit layout of a violation message
+func blocks(message NotificationMessage) []slack.Block {
+	object := message.ResourceName
+	if object != "" && message.ResourceNamespace != "" {
+		object = message.ResourceNamespace + "/" + object
+	}
+	candidates := []struct{ title, value string }{
+		{"Kind", message.ResourceType},
+		{"Object", object},
+		{"Namespace", message.ResourceNamespace},
+		{"Requested by", message.User},
+		{"Operation", message.Operation},
+		{"Cluster", message.Cluster},
+		{"Field", "`" + message.Field + "`"},
+		{"Element", message.Element},
+		{"Container", message.Container},
+		{"Value", "`" + message.Value + "`"},
+	}
+	var fields []*slack.TextBlockObject
+	for _, c := range candidates {
+		if strings.Trim(c.value, "`/") == "" {
+			continue
+		}
+		fields = append(fields, field(c.title, c.value))
+	}
+
+	bs := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, message.Message, false, false), nil, nil),
+	}
+	if len(fields) > 0 {
+		bs = append(bs, slack.NewSectionBlock(nil, fields, nil))
+	}
+	if message.RemediationURL != "" {
+		button := slack.NewButtonBlockElement("remediation", message.RemediationURL, slack.NewTextBlockObject(slack.PlainTextType, "How to fix it", false, false))
+		button.URL = message.RemediationURL
+		bs = append(bs, slack.NewActionBlock("remediation", button))
+	}
+	return bs
+}
+
+//NotifyViolation posts a single violation message into a Slack channel
+func NotifyViolation(message NotificationMessage, botToken, channelString string, options ...slack.Option) error {
 	ts := strconv.FormatInt(time.Now().Unix(), 10)
-	api := slack.New(botToken)
+	api := slack.New(botToken, options...)
+	mode := message.EnforcementMode
+	if mode == "" {
+		mode = "deny"
+	}
 	attachment := slack.Attachment{
-		Fallback: "A rule violation has occurred.",
-		Pretext:  "The following rules violations has been occurred:",
-		Text:     message.Message,
+		Fallback: fmt.Sprintf("A rule violation has occurred on %s %s.", message.ResourceType, message.ResourceName),
+		Text:     fmt.Sprintf("Enforcement mode: *%s*", mode),
 		Footer:   "Aegir",
 		Ts:       json.Number(ts),
-		Color:    color,
-		Fields: []slack.AttachmentField{
-			slack.AttachmentField{
-				Title: "ResourceType",
-				Value: message.ResourceType,
-			},
-			slack.AttachmentField{
-				Title: "Namespace",
-				Value: message.ResourceNamespace,
-			},
-		},
-	}
-
-	_, timestamp, err := api.PostMessage(channelString, slack.MsgOptionText("", true), slack.MsgOptionAttachments(attachment))
-	if channelString == "" || botToken == "" {
-		return
+		Color:    ColorFor(mode),
 	}
+
+	_, timestamp, err := api.PostMessage(channelString,
+		slack.MsgOptionText("The following rule violations occurred:", false),
+		slack.MsgOptionBlocks(blocks(message)...),
+		slack.MsgOptionAttachments(attachment))
 	if err != nil {
-		log.Printf("Error sending slack notification: %s\n", err)
-		return
+		return err
 	}
 	log.Printf("Message successfully sent to channel %s at %s\n", channelString, timestamp)
+	return nil
 }
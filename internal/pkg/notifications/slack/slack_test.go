@@ -0,0 +1,155 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nlopes/slack"
+)
+
+type postedMessage struct {
+	channel     string
+	text        string
+	blocks      string
+	attachments []slack.Attachment
+}
+
+//mockSlack starts a fake Slack API recording every chat.postMessage call
+func mockSlack(t *testing.T, status int) (*httptest.Server, func() []postedMessage) {
+	var mu sync.Mutex
+	var posted []postedMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" {
+			t.Errorf("unexpected Slack API call to %s", r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("could not parse form: %v", err)
+		}
+		var attachments []slack.Attachment
+		json.Unmarshal([]byte(r.Form.Get("attachments")), &attachments)
+		mu.Lock()
+		posted = append(posted, postedMessage{
+			channel:     r.Form.Get("channel"),
+			text:        r.Form.Get("text"),
+			blocks:      r.Form.Get("blocks"),
+			attachments: attachments,
+		})
+		mu.Unlock()
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"ok": true, "channel": "C123", "ts": "1600000000.000100"}`))
+	}))
+	return server, func() []postedMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return posted
+	}
+}
+
+var testMessage = NotificationMessage{
+	Message:           "Rule name: *image_tag*",
+	ResourceType:      "Deployment",
+	ResourceNamespace: "platform",
+	ResourceName:      "authnetes",
+	User:              "jane@example.com",
+	Operation:         "CREATE",
+	Cluster:           "production",
+	Field:             "spec.template.spec.containers.0.image",
+	Container:         "authnetes (containers)",
+	Value:             "authnetes:latest",
+	RemediationURL:    "https://runbooks.example.com/image_tag",
+	EnforcementMode:   "warn",
+}
+
+func TestNotifyViolationPostsBlocks(t *testing.T) {
+	server, posted := mockSlack(t, http.StatusOK)
+	defer server.Close()
+
+	if err := NotifyViolation(testMessage, "token", "#platform", slack.OptionAPIURL(server.URL+"/")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	messages := posted()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 posted message but got %d", len(messages))
+	}
+	msg := messages[0]
+	if msg.channel != "#platform" {
+		t.Errorf("expected '%s' but got '%s'", "#platform", msg.channel)
+	}
+	if msg.text != "The following rule violations occurred:" {
+		t.Errorf("expected '%s' but got '%s'", "The following rule violations occurred:", msg.text)
+	}
+	for _, expected := range []string{
+		"Rule name: *image_tag*",
+		"platform/authnetes",
+		"jane@example.com",
+		"CREATE",
+		"production",
+		"authnetes (containers)",
+		"authnetes:latest",
+		"https://runbooks.example.com/image_tag",
+	} {
+		if !strings.Contains(msg.blocks, expected) {
+			t.Errorf("expected blocks to contain '%s' but got '%s'", expected, msg.blocks)
+		}
+	}
+	if len(msg.attachments) != 1 || msg.attachments[0].Color != ColorWarn {
+		t.Errorf("expected one attachment colored '%s' but got '%+v'", ColorWarn, msg.attachments)
+	}
+}
+
+func TestNotifyViolationDefaultsToDenyColor(t *testing.T) {
+	server, posted := mockSlack(t, http.StatusOK)
+	defer server.Close()
+
+	msg := testMessage
+	msg.EnforcementMode = ""
+	msg.RemediationURL = ""
+	if err := NotifyViolation(msg, "token", "#platform", slack.OptionAPIURL(server.URL+"/")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sent := posted()[0]
+	if sent.attachments[0].Color != ColorDeny {
+		t.Errorf("expected '%s' but got '%s'", ColorDeny, sent.attachments[0].Color)
+	}
+	if strings.Contains(sent.blocks, `"actions"`) {
+		t.Errorf("expected no remediation button but got '%s'", sent.blocks)
+	}
+}
+
+func TestNotifierRetriesServerErrors(t *testing.T) {
+	server, posted := mockSlack(t, http.StatusInternalServerError)
+	defer server.Close()
+
+	opts := testOptions()
+	opts.MaxRetries = 2
+	opts.APIURL = server.URL + "/"
+	n := NewNotifier("token", opts)
+	n.Enqueue(testMessage, "#platform")
+	n.Close(context.Background())
+	if got := len(posted()); got != 3 {
+		t.Errorf("expected 3 attempts but got %d", got)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	cases := map[string]interface{}{
+		"":               nil,
+		"nginx":          "nginx",
+		"0":              float64(0),
+		`{"cpu":"100m"}`: map[string]interface{}{"cpu": "100m"},
+		`["a","b"]`:      []interface{}{"a", "b"},
+	}
+	for expected, value := range cases {
+		if result := FormatValue(value); result != expected {
+			t.Errorf("expected '%s' but got '%s'", expected, result)
+		}
+	}
+}
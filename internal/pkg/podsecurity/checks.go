@@ -0,0 +1,148 @@
+package podsecurity
+
+import "strings"
+
+//check is a control of the Pod Security Standards written as a CEL expression.
+//{spec} and {metadata} are replaced by the pod spec and the pod metadata of each kind,
+//and containers(cond) holds when cond holds for every container c of every container list.
+type check struct {
+	Name        string
+	Level       string
+	Description string
+	Expression  string
+	Message     string
+}
+
+const (
+	allowedCapabilities = `['AUDIT_WRITE', 'CHOWN', 'DAC_OVERRIDE', 'FOWNER', 'FSETID', 'KILL', 'MKNOD', 'NET_BIND_SERVICE', 'SETFCAP', 'SETGID', 'SETPCAP', 'SETUID', 'SYS_CHROOT']`
+	allowedSELinuxTypes = `['container_t', 'container_init_t', 'container_kvm_t']`
+	safeSysctls         = `['kernel.shm_rmid_forced', 'net.ipv4.ip_local_port_range', 'net.ipv4.tcp_syncookies', 'net.ipv4.ping_group_range']`
+	allowedVolumes      = `['name', 'configMap', 'csi', 'downwardAPI', 'emptyDir', 'ephemeral', 'persistentVolumeClaim', 'projected', 'secret']`
+	seccompTypes        = `['RuntimeDefault', 'Localhost']`
+	seLinuxOptions      = `(!has(%[1]s.seLinuxOptions) || ((!has(%[1]s.seLinuxOptions.type) || %[1]s.seLinuxOptions.type in ` + allowedSELinuxTypes + `) && !has(%[1]s.seLinuxOptions.user) && !has(%[1]s.seLinuxOptions.role)))`
+)
+
+//versions holds every released version of the rule pack, checks of a version never change
+var versions = map[string][]check{
+	"v1": {
+		{
+			Name:        "host-process",
+			Level:       LevelBaseline,
+			Description: "Windows HostProcess containers must be disallowed",
+			Expression:  "(!has({spec}.securityContext) || !has({spec}.securityContext.windowsOptions) || !has({spec}.securityContext.windowsOptions.hostProcess) || !{spec}.securityContext.windowsOptions.hostProcess) && containers(!has(c.securityContext) || !has(c.securityContext.windowsOptions) || !has(c.securityContext.windowsOptions.hostProcess) || !c.securityContext.windowsOptions.hostProcess)",
+			Message:     "securityContext.windowsOptions.hostProcess must be unset or false, in the pod and in every container",
+		},
+		{
+			Name:        "host-namespaces",
+			Level:       LevelBaseline,
+			Description: "Sharing the host namespaces must be disallowed",
+			Expression:  "!(has({spec}.hostNetwork) && {spec}.hostNetwork) && !(has({spec}.hostPID) && {spec}.hostPID) && !(has({spec}.hostIPC) && {spec}.hostIPC)",
+			Message:     "hostNetwork, hostPID and hostIPC must be unset or false",
+		},
+		{
+			Name:        "privileged",
+			Level:       LevelBaseline,
+			Description: "Privileged containers must be disallowed",
+			Expression:  "containers(!has(c.securityContext) || !has(c.securityContext.privileged) || !c.securityContext.privileged)",
+			Message:     "securityContext.privileged must be unset or false",
+		},
+		{
+			Name:        "capabilities",
+			Level:       LevelBaseline,
+			Description: "Adding capabilities beyond the default set must be disallowed",
+			Expression:  "containers(!has(c.securityContext) || !has(c.securityContext.capabilities) || !has(c.securityContext.capabilities.add) || c.securityContext.capabilities.add.all(cap, cap in " + allowedCapabilities + "))",
+			Message:     "securityContext.capabilities.add can only hold " + allowedCapabilities,
+		},
+		{
+			Name:        "host-path-volumes",
+			Level:       LevelBaseline,
+			Description: "HostPath volumes must be forbidden",
+			Expression:  "!has({spec}.volumes) || {spec}.volumes.all(v, !has(v.hostPath))",
+			Message:     "volumes can't be hostPath",
+		},
+		{
+			Name:        "host-ports",
+			Level:       LevelBaseline,
+			Description: "HostPorts must be disallowed",
+			Expression:  "containers(!has(c.ports) || c.ports.all(p, !has(p.hostPort) || p.hostPort == 0))",
+			Message:     "ports[].hostPort must be unset or 0",
+		},
+		{
+			Name:        "apparmor",
+			Level:       LevelBaseline,
+			Description: "Overriding the default AppArmor profile must be disallowed",
+			Expression:  "!has({metadata}) || !has({metadata}.annotations) || {metadata}.annotations.all(k, !k.startsWith('container.apparmor.security.beta.kubernetes.io/') || {metadata}.annotations[k] == 'runtime/default' || {metadata}.annotations[k].startsWith('localhost/'))",
+			Message:     "AppArmor annotations must be runtime/default or localhost/*",
+		},
+		{
+			Name:        "selinux",
+			Level:       LevelBaseline,
+			Description: "Setting custom SELinux options must be disallowed",
+			Expression:  "(!has({spec}.securityContext) || " + strings.ReplaceAll(seLinuxOptions, "%[1]s", "{spec}.securityContext") + ") && containers(!has(c.securityContext) || " + strings.ReplaceAll(seLinuxOptions, "%[1]s", "c.securityContext") + ")",
+			Message:     "seLinuxOptions.type can only be " + allowedSELinuxTypes + ", seLinuxOptions.user and seLinuxOptions.role must be unset",
+		},
+		{
+			Name:        "proc-mount",
+			Level:       LevelBaseline,
+			Description: "The default /proc masks must be required",
+			Expression:  "containers(!has(c.securityContext) || !has(c.securityContext.procMount) || c.securityContext.procMount == 'Default')",
+			Message:     "securityContext.procMount must be unset or Default",
+		},
+		{
+			Name:        "seccomp-unconfined",
+			Level:       LevelBaseline,
+			Description: "Seccomp profiles can't be Unconfined",
+			Expression:  "(!has({spec}.securityContext) || !has({spec}.securityContext.seccompProfile) || !has({spec}.securityContext.seccompProfile.type) || {spec}.securityContext.seccompProfile.type != 'Unconfined') && containers(!has(c.securityContext) || !has(c.securityContext.seccompProfile) || !has(c.securityContext.seccompProfile.type) || c.securityContext.seccompProfile.type != 'Unconfined')",
+			Message:     "securityContext.seccompProfile.type can't be Unconfined, in the pod or in any container",
+		},
+		{
+			Name:        "sysctls",
+			Level:       LevelBaseline,
+			Description: "Sysctls must be limited to the safe subset",
+			Expression:  "!has({spec}.securityContext) || !has({spec}.securityContext.sysctls) || {spec}.securityContext.sysctls.all(s, s.name in " + safeSysctls + ")",
+			Message:     "securityContext.sysctls can only hold " + safeSysctls,
+		},
+		{
+			Name:        "volume-types",
+			Level:       LevelRestricted,
+			Description: "Only volumes that don't expose the node can be used",
+			Expression:  "!has({spec}.volumes) || {spec}.volumes.all(v, v.all(k, k in " + allowedVolumes + "))",
+			Message:     "volumes can only be configMap, csi, downwardAPI, emptyDir, ephemeral, persistentVolumeClaim, projected or secret",
+		},
+		{
+			Name:        "privilege-escalation",
+			Level:       LevelRestricted,
+			Description: "Privilege escalation must be disallowed",
+			Expression:  "containers(has(c.securityContext) && has(c.securityContext.allowPrivilegeEscalation) && c.securityContext.allowPrivilegeEscalation == false)",
+			Message:     "securityContext.allowPrivilegeEscalation must be false",
+		},
+		{
+			Name:        "run-as-non-root",
+			Level:       LevelRestricted,
+			Description: "Containers must run as non-root users",
+			Expression:  "(has({spec}.securityContext) && has({spec}.securityContext.runAsNonRoot) && {spec}.securityContext.runAsNonRoot) ? containers(!has(c.securityContext) || !has(c.securityContext.runAsNonRoot) || c.securityContext.runAsNonRoot) : containers(has(c.securityContext) && has(c.securityContext.runAsNonRoot) && c.securityContext.runAsNonRoot)",
+			Message:     "securityContext.runAsNonRoot must be true, in the pod or in every container",
+		},
+		{
+			Name:        "run-as-user",
+			Level:       LevelRestricted,
+			Description: "Containers can't run as the root user",
+			Expression:  "(!has({spec}.securityContext) || !has({spec}.securityContext.runAsUser) || {spec}.securityContext.runAsUser != 0) && containers(!has(c.securityContext) || !has(c.securityContext.runAsUser) || c.securityContext.runAsUser != 0)",
+			Message:     "securityContext.runAsUser can't be 0, in the pod or in any container",
+		},
+		{
+			Name:        "seccomp",
+			Level:       LevelRestricted,
+			Description: "A seccomp profile must be set",
+			Expression:  "(has({spec}.securityContext) && has({spec}.securityContext.seccompProfile) && {spec}.securityContext.seccompProfile.type in " + seccompTypes + ") ? containers(!has(c.securityContext) || !has(c.securityContext.seccompProfile) || c.securityContext.seccompProfile.type in " + seccompTypes + ") : containers(has(c.securityContext) && has(c.securityContext.seccompProfile) && c.securityContext.seccompProfile.type in " + seccompTypes + ")",
+			Message:     "securityContext.seccompProfile.type must be RuntimeDefault or Localhost, in the pod or in every container",
+		},
+		{
+			Name:        "capabilities-drop-all",
+			Level:       LevelRestricted,
+			Description: "Containers must drop all capabilities",
+			Expression:  "containers(has(c.securityContext) && has(c.securityContext.capabilities) && has(c.securityContext.capabilities.drop) && c.securityContext.capabilities.drop.exists(d, d == 'ALL') && (!has(c.securityContext.capabilities.add) || c.securityContext.capabilities.add.all(a, a == 'NET_BIND_SERVICE')))",
+			Message:     "securityContext.capabilities.drop must hold ALL, and add can only hold NET_BIND_SERVICE",
+		},
+	},
+}
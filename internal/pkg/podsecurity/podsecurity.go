@@ -0,0 +1,157 @@
+//Package podsecurity implements the Kubernetes Pod Security Standards as a bundled rule pack.
+//See https://kubernetes.io/docs/concepts/security/pod-security-standards/
+package podsecurity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grupozap/aegir/internal/pkg/kinds"
+	"github.com/grupozap/aegir/internal/pkg/rules"
+)
+
+const (
+	//LevelBaseline prevents known privilege escalations
+	LevelBaseline = "baseline"
+	//LevelRestricted enforces pod hardening best practices, it includes the baseline checks
+	LevelRestricted = "restricted"
+	//LatestVersion is used when the version of the rule pack is not pinned
+	LatestVersion = "v1"
+)
+
+//Versions returns the released versions of the rule pack
+func Versions() []string {
+	vs := make([]string, 0, len(versions))
+	for v := range versions {
+		vs = append(vs, v)
+	}
+	sort.Strings(vs)
+	return vs
+}
+
+//includes reports whether level enables the checks of another level
+func includes(level, other string) bool {
+	return level == other || (level == LevelRestricted && other == LevelBaseline)
+}
+
+//versionOf returns the version of the rule pack enabled by ps
+func versionOf(ps rules.PodSecurity) string {
+	if ps.Version == "" || ps.Version == "latest" {
+		return LatestVersion
+	}
+	return ps.Version
+}
+
+//enforcedAlike reports whether two entries enforce a check the same way: same version, enforcement mode and notification channel
+func enforcedAlike(ps, other rules.PodSecurity) bool {
+	mode := func(ps rules.PodSecurity) string {
+		if ps.EnforcementMode == "" {
+			return rules.EnforcementDeny
+		}
+		return ps.EnforcementMode
+	}
+	return versionOf(ps) == versionOf(other) && mode(ps) == mode(other) && ps.SlackNotificationChannel == other.SlackNotificationChannel
+}
+
+//Expand appends the rules of the levels enabled by rl.PodSecurity to rl.Rules, for every kind holding a pod spec.
+//A check enabled in every namespace is not repeated for a namespace enabling a stricter level, unless the namespace
+//enforces it differently, like denying what is only warned about everywhere else.
+func Expand(rl *rules.RulesList) error {
+	var everywhere []rules.PodSecurity
+	for _, ps := range rl.PodSecurity {
+		if ps.Namespace == "*" {
+			everywhere = append(everywhere, ps)
+		}
+	}
+	//covered reports whether an entry for every namespace already enforces check c like ps
+	covered := func(c check, ps rules.PodSecurity) bool {
+		for _, e := range everywhere {
+			if includes(e.Level, c.Level) && enforcedAlike(e, ps) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, ps := range rl.PodSecurity {
+		if ps.Namespace == "" {
+			return fmt.Errorf("pod_security needs a namespace, use \"*\" to enable it in every namespace")
+		}
+		if ps.Level != LevelBaseline && ps.Level != LevelRestricted {
+			return fmt.Errorf("pod_security of namespace %s has an unknown level %q, use %q or %q", ps.Namespace, ps.Level, LevelBaseline, LevelRestricted)
+		}
+		version := versionOf(ps)
+		checks, ok := versions[version]
+		if !ok {
+			return fmt.Errorf("pod_security of namespace %s has an unknown version %q, use one of %s", ps.Namespace, ps.Version, strings.Join(Versions(), ", "))
+		}
+		for _, c := range checks {
+			if !includes(ps.Level, c.Level) {
+				continue
+			}
+			if ps.Namespace != "*" && covered(c, ps) {
+				continue
+			}
+			for _, kind := range kinds.PodKinds() {
+				rl.Rules = append(rl.Rules, rule(c, version, kind, ps))
+			}
+		}
+	}
+	return nil
+}
+
+func rule(c check, version, kind string, ps rules.PodSecurity) *rules.Rule {
+	spec, _ := kinds.PodSpecPath(kind)
+	metadata, _ := kinds.PodMetadataPath(kind)
+	return &rules.Rule{
+		Name:                     fmt.Sprintf("pod-security/%s/%s/%s", version, c.Level, c.Name),
+		Namespace:                ps.Namespace,
+		ResourceType:             kind,
+		EnforcementMode:          ps.EnforcementMode,
+		SlackNotificationChannel: ps.SlackNotificationChannel,
+		RulesDefinitions: []rules.RuleDefinition{{
+			Field: spec,
+			CEL: &rules.CELRule{
+				Description: c.Description,
+				Expression:  expression(c.Expression, "object."+spec, "object."+metadata),
+				Message:     c.Message,
+			},
+		}},
+	}
+}
+
+//expression renders a check for a pod spec and pod metadata paths
+func expression(e, spec, metadata string) string {
+	e = expandContainers(e)
+	e = strings.ReplaceAll(e, "{spec}", spec)
+	return strings.ReplaceAll(e, "{metadata}", metadata)
+}
+
+//expandContainers replaces every containers(cond) by cond applied to each container list
+func expandContainers(e string) string {
+	const call = "containers("
+	for {
+		start := strings.Index(e, call)
+		if start < 0 {
+			return e
+		}
+		depth, end := 0, -1
+		for i := start + len(call) - 1; i < len(e) && end < 0; i++ {
+			switch e[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					end = i
+				}
+			}
+		}
+		cond := e[start+len(call) : end]
+		parts := make([]string, 0, len(rules.ContainerLists))
+		for _, list := range rules.ContainerLists {
+			parts = append(parts, fmt.Sprintf("(!has({spec}.%[1]s) || {spec}.%[1]s.all(c, %[2]s))", list, cond))
+		}
+		e = e[:start] + "(" + strings.Join(parts, " && ") + ")" + e[end+1:]
+	}
+}
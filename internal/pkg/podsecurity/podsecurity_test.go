@@ -0,0 +1,158 @@
+package podsecurity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grupozap/aegir/internal/pkg/rules"
+	"gotest.tools/assert"
+)
+
+const restrictedPod = `{
+    "kind": "Pod",
+    "metadata": {"name": "app"},
+    "spec": {
+        "securityContext": {"runAsNonRoot": true, "seccompProfile": {"type": "RuntimeDefault"}},
+        "containers": [{
+            "name": "app",
+            "image": "app:1.0",
+            "securityContext": {"allowPrivilegeEscalation": false, "capabilities": {"drop": ["ALL"]}}
+        }],
+        "volumes": [{"name": "config", "configMap": {"name": "app"}}]
+    }
+}`
+
+const privilegedPod = `{
+    "kind": "Pod",
+    "metadata": {"name": "app", "annotations": {"container.apparmor.security.beta.kubernetes.io/app": "unconfined"}},
+    "spec": {
+        "hostNetwork": true,
+        "initContainers": [{"name": "init", "securityContext": {"privileged": true}}],
+        "containers": [{
+            "name": "app",
+            "ports": [{"containerPort": 80, "hostPort": 80}],
+            "securityContext": {"capabilities": {"add": ["SYS_ADMIN"]}, "procMount": "Unmasked", "seLinuxOptions": {"user": "root"}}
+        }],
+        "securityContext": {"sysctls": [{"name": "kernel.msgmax", "value": "65536"}], "seccompProfile": {"type": "Unconfined"}, "windowsOptions": {"hostProcess": true}},
+        "volumes": [{"name": "docker", "hostPath": {"path": "/var/run/docker.sock"}}]
+    }
+}`
+
+//violated returns the names of the checks of a level violated by a Pod
+func violated(t *testing.T, level, pod string) []string {
+	rl := &rules.RulesList{PodSecurity: []rules.PodSecurity{{Namespace: "*", Level: level}}}
+	assert.NilError(t, Expand(rl))
+	var names []string
+	for _, rule := range rl.Rules {
+		if rule.ResourceType != "Pod" {
+			continue
+		}
+		for _, ruledef := range rule.RulesDefinitions {
+			assert.NilError(t, ruledef.Compile(), rule.Name)
+			for _, v := range ruledef.Evaluate(&rules.Input{Object: pod}) {
+				assert.Assert(t, !strings.Contains(v.Message, "could not be evaluated"), "%s: %s", rule.Name, v.Message)
+				names = append(names, rule.Name)
+			}
+		}
+	}
+	return names
+}
+
+func TestBaseline(t *testing.T) {
+	assert.DeepEqual(t, violated(t, LevelBaseline, restrictedPod), []string(nil))
+	assert.DeepEqual(t, violated(t, LevelBaseline, privilegedPod), []string{
+		"pod-security/v1/baseline/host-process",
+		"pod-security/v1/baseline/host-namespaces",
+		"pod-security/v1/baseline/privileged",
+		"pod-security/v1/baseline/capabilities",
+		"pod-security/v1/baseline/host-path-volumes",
+		"pod-security/v1/baseline/host-ports",
+		"pod-security/v1/baseline/apparmor",
+		"pod-security/v1/baseline/selinux",
+		"pod-security/v1/baseline/proc-mount",
+		"pod-security/v1/baseline/seccomp-unconfined",
+		"pod-security/v1/baseline/sysctls",
+	})
+	assert.DeepEqual(t, violated(t, LevelBaseline, `{"kind": "Pod", "spec": {"containers": [{"name": "app", "securityContext": {"seccompProfile": {"type": "Unconfined"}, "windowsOptions": {"hostProcess": true}}}]}}`), []string{
+		"pod-security/v1/baseline/host-process",
+		"pod-security/v1/baseline/seccomp-unconfined",
+	})
+}
+
+func TestRestricted(t *testing.T) {
+	assert.DeepEqual(t, violated(t, LevelRestricted, restrictedPod), []string(nil))
+	names := violated(t, LevelRestricted, `{"kind": "Pod", "spec": {"containers": [{"name": "app"}]}}`)
+	assert.DeepEqual(t, names, []string{
+		"pod-security/v1/restricted/privilege-escalation",
+		"pod-security/v1/restricted/run-as-non-root",
+		"pod-security/v1/restricted/seccomp",
+		"pod-security/v1/restricted/capabilities-drop-all",
+	})
+	rootPod := strings.Replace(restrictedPod, `"runAsNonRoot": true`, `"runAsNonRoot": true, "runAsUser": 0`, 1)
+	assert.DeepEqual(t, violated(t, LevelRestricted, rootPod), []string{"pod-security/v1/restricted/run-as-user"})
+}
+
+func TestExpandPodTemplates(t *testing.T) {
+	rl := &rules.RulesList{PodSecurity: []rules.PodSecurity{{Namespace: "*", Level: LevelBaseline, EnforcementMode: "warn"}}}
+	assert.NilError(t, Expand(rl))
+	var cronJob *rules.Rule
+	for _, rule := range rl.Rules {
+		if rule.ResourceType == "CronJob" && rule.Name == "pod-security/v1/baseline/host-namespaces" {
+			cronJob = rule
+		}
+	}
+	assert.Assert(t, cronJob != nil)
+	assert.Equal(t, cronJob.EnforcementMode, "warn")
+	assert.Equal(t, cronJob.RulesDefinitions[0].Field, "spec.jobTemplate.spec.template.spec")
+
+	violations := cronJob.RulesDefinitions[0].Evaluate(&rules.Input{Object: `{"spec": {"jobTemplate": {"spec": {"template": {"spec": {"hostPID": true}}}}}}`})
+	assert.Equal(t, len(violations), 1)
+}
+
+func TestExpandDoesNotRepeatChecks(t *testing.T) {
+	rl := &rules.RulesList{PodSecurity: []rules.PodSecurity{
+		{Namespace: "*", Level: LevelBaseline},
+		{Namespace: "payments", Level: LevelRestricted},
+	}}
+	assert.NilError(t, Expand(rl))
+	for _, rule := range rl.Rules {
+		if rule.Namespace == "payments" {
+			assert.Assert(t, strings.Contains(rule.Name, LevelRestricted), rule.Name)
+		}
+	}
+}
+
+func TestExpandRepeatsChecksEnforcedDifferently(t *testing.T) {
+	rl := &rules.RulesList{PodSecurity: []rules.PodSecurity{
+		{Namespace: "*", Level: LevelBaseline, EnforcementMode: rules.EnforcementWarn},
+		{Namespace: "payments", Level: LevelRestricted},
+		{Namespace: "billing", Level: LevelRestricted, EnforcementMode: rules.EnforcementWarn, SlackNotificationChannel: "#billing"},
+		{Namespace: "search", Level: LevelRestricted, EnforcementMode: rules.EnforcementWarn},
+	}}
+	assert.NilError(t, Expand(rl))
+	modes := map[string]string{}
+	for _, rule := range rl.Rules {
+		if rule.ResourceType == "Pod" && strings.HasSuffix(rule.Name, "/baseline/privileged") {
+			modes[rule.Namespace] = rule.EnforcementMode
+		}
+	}
+	assert.DeepEqual(t, modes, map[string]string{
+		"*":        rules.EnforcementWarn,
+		"payments": "",
+		"billing":  rules.EnforcementWarn,
+	})
+
+	privileged := `{"kind": "Pod", "metadata": {"name": "app"}, "spec": {"containers": [{"name": "app", "securityContext": {"privileged": true}}]}}`
+	for _, rule := range rl.Rules {
+		if rule.ResourceType == "Pod" && rule.Namespace == "payments" && strings.HasSuffix(rule.Name, "/baseline/privileged") {
+			assert.NilError(t, rule.RulesDefinitions[0].Compile())
+			assert.Equal(t, len(rule.RulesDefinitions[0].Evaluate(&rules.Input{Object: privileged})), 1)
+		}
+	}
+}
+
+func TestExpandInvalid(t *testing.T) {
+	assert.ErrorContains(t, Expand(&rules.RulesList{PodSecurity: []rules.PodSecurity{{Namespace: "*", Level: "privileged"}}}), `unknown level "privileged"`)
+	assert.ErrorContains(t, Expand(&rules.RulesList{PodSecurity: []rules.PodSecurity{{Namespace: "*", Level: LevelBaseline, Version: "v0"}}}), `unknown version "v0", use one of v1`)
+	assert.ErrorContains(t, Expand(&rules.RulesList{PodSecurity: []rules.PodSecurity{{Level: LevelBaseline}}}), "pod_security needs a namespace")
+}
@@ -0,0 +1,214 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/grupozap/aegir/internal/pkg/utils"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+//CELRule is a rule definition written as a CEL expression, the object is valid when it evaluates to true.
+//The expression can read object, oldObject, request, namespaceObject, podSpec in rules on kinds holding a pod spec
+//and container in definitions scoped to containers.
+//quantity(x) converts a Kubernetes quantity like "512Mi" or "500m" to a number, so quantities can be compared.
+type CELRule struct {
+	Description string `yaml:"description"`
+	Expression  string `yaml:"expression"`
+	//Message replaces the default error of a violation
+	Message string `yaml:"message,omitempty"`
+}
+
+//Input holds the request a rule definition is evaluated against
+type Input struct {
+	//Object and OldObject are the JSON of the object in the request, OldObject is empty on CREATE
+	Object    string
+	OldObject string
+	//Request is the admission request without the objects
+	Request map[string]interface{}
+	//NamespaceObject returns the Namespace of the object, it is only called by expressions using it
+	NamespaceObject func() map[string]interface{}
+}
+
+var celEnv, celEnvErr = cel.NewEnv(cel.Declarations(
+	decls.NewVar("object", decls.Dyn),
+	decls.NewVar("oldObject", decls.Dyn),
+	decls.NewVar("request", decls.Dyn),
+	decls.NewVar("namespaceObject", decls.Dyn),
+	decls.NewVar("podSpec", decls.Dyn),
+	decls.NewVar("container", decls.Dyn),
+	decls.NewFunction("quantity", decls.NewOverload("quantity_dyn", []*exprpb.Type{decls.Dyn}, decls.Double)),
+))
+
+//celQuantity implements quantity(x) for strings like "1Gi" and numbers
+var celQuantity = &functions.Overload{
+	Operator: "quantity",
+	Unary: func(value ref.Val) ref.Val {
+		var s string
+		switch v := value.Value().(type) {
+		case string:
+			s = v
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return types.NewErr("quantity(%v): not a quantity", value.Value())
+		}
+		q, err := resource.ParseQuantity(s)
+		if err != nil {
+			return types.NewErr("quantity(%q): %v", s, err)
+		}
+		f, err := strconv.ParseFloat(q.AsDec().String(), 64)
+		if err != nil {
+			return types.NewErr("quantity(%q): %v", s, err)
+		}
+		return types.Double(f)
+	},
+}
+
+//compileCEL parses and type checks an expression, which must return a bool
+func compileCEL(expression string) (cel.Program, error) {
+	if celEnvErr != nil {
+		return nil, celEnvErr
+	}
+	if strings.TrimSpace(expression) == "" {
+		return nil, fmt.Errorf("cel.expression is empty")
+	}
+	ast, issues := celEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid cel.expression: %v", issues.Err())
+	}
+	if t := ast.ResultType(); t.GetPrimitive() != exprpb.Type_BOOL && t.GetDyn() == nil {
+		return nil, fmt.Errorf("cel.expression must return a bool")
+	}
+	return celEnv.Program(ast, cel.Functions(celQuantity))
+}
+
+//UsesNamespaceObject reports whether any CEL expression in rl reads the namespaceObject
+func UsesNamespaceObject(rl *RulesList) bool {
+	for _, rule := range rl.Rules {
+		for _, ruledef := range rule.RulesDefinitions {
+			if ruledef.CEL != nil && strings.Contains(ruledef.CEL.Expression, "namespaceObject") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//decodeJSON unmarshals a JSON document keeping integers as int64, so they compare with CEL int literals
+func decodeJSON(s string) (interface{}, error) {
+	if s == "" {
+		return nil, nil
+	}
+	d := json.NewDecoder(bytes.NewBufferString(s))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return numbers(v), nil
+}
+
+func numbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range value {
+			value[k] = numbers(e)
+		}
+	case []interface{}:
+		for i, e := range value {
+			value[i] = numbers(e)
+		}
+	}
+	return v
+}
+
+//celVariables returns the variables of an expression, every evaluation needs its own map
+func (in *Input) celVariables() (map[string]interface{}, error) {
+	object, err := decodeJSON(in.Object)
+	if err != nil {
+		return nil, err
+	}
+	oldObject, err := decodeJSON(in.OldObject)
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]interface{}{
+		"object":    object,
+		"oldObject": oldObject,
+		"request":   in.Request,
+		"namespaceObject": func() interface{} {
+			if in.NamespaceObject == nil {
+				return nil
+			}
+			return in.NamespaceObject()
+		},
+	}
+	if in.Request == nil {
+		vars["request"] = map[string]interface{}{}
+	}
+	return vars, nil
+}
+
+func (ruledef *RuleDefinition) celViolations(in *Input, c *container) []*utils.Violation {
+	violation := func(msg string) []*utils.Violation {
+		return []*utils.Violation{{
+			Description: ruledef.CEL.Description,
+			JSONPath:    ruledef.Field,
+			Message:     msg,
+		}}
+	}
+	failure := func(format string, args ...interface{}) []*utils.Violation {
+		v := ruledef.EvaluationError(format, args...)
+		v.Description = ruledef.CEL.Description
+		return []*utils.Violation{v}
+	}
+	program := ruledef.program
+	if program == nil {
+		var err error
+		if program, err = compileCEL(ruledef.CEL.Expression); err != nil {
+			return failure("%v", err)
+		}
+	}
+	vars, err := in.celVariables()
+	if err != nil {
+		return failure("could not decode the object: %v", err)
+	}
+	vars["podSpec"], vars["container"] = nil, nil
+	if ruledef.podSpec != "" {
+		vars["podSpec"] = lookup(vars["object"], ruledef.podSpec)
+	}
+	if c != nil {
+		vars["container"] = c.decode()
+	}
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return failure("Expression: %s could not be evaluated: %v", ruledef.CEL.Expression, err)
+	}
+	if valid, ok := out.Value().(bool); !ok {
+		return failure("Expression: %s returned %v instead of a bool", ruledef.CEL.Expression, out.Value())
+	} else if valid {
+		return nil
+	}
+	if ruledef.CEL.Message != "" {
+		return violation(ruledef.CEL.Message)
+	}
+	return violation(fmt.Sprintf("Expression: %s is false", ruledef.CEL.Expression))
+}
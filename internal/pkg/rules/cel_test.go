@@ -0,0 +1,146 @@
+package rules
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+var limitsPod = `{
+    "kind": "Pod",
+    "metadata": {"name": "app", "namespace": "team-a"},
+    "spec": {
+        "hostNetwork": true,
+        "replicas": 3,
+        "containers": [
+            {"name": "app", "resources": {"requests": {"memory": "512Mi"}, "limits": {"memory": "256Mi"}}}
+        ]
+    }
+}`
+
+func celRule(expression string) RuleDefinition {
+	return RuleDefinition{CEL: &CELRule{Description: "test", Expression: expression}}
+}
+
+func TestEvaluateCEL(t *testing.T) {
+	in := &Input{
+		Object:  limitsPod,
+		Request: map[string]interface{}{"namespace": "team-a", "operation": "CREATE"},
+		NamespaceObject: func() map[string]interface{} {
+			return map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{"tier": "system"}}}
+		},
+	}
+	valid := []string{
+		"object.spec.replicas > 2",
+		"oldObject == null",
+		"request.operation == 'CREATE'",
+		"namespaceObject.metadata.labels.tier == 'system'",
+		"!object.spec.hostNetwork || namespaceObject.metadata.labels.tier == 'system'",
+	}
+	for _, expression := range valid {
+		ruledef := celRule(expression)
+		assert.Equal(t, len(ruledef.Evaluate(in)), 0, expression)
+	}
+
+	ruledef := celRule("object.spec.containers.all(c, quantity(c.resources.limits.memory) >= quantity(c.resources.requests.memory))")
+	violations := ruledef.Evaluate(in)
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].Description, "test")
+	assert.Equal(t, violations[0].Message, "Expression: object.spec.containers.all(c, quantity(c.resources.limits.memory) >= quantity(c.resources.requests.memory)) is false")
+
+	ruledef = celRule("!object.spec.hostNetwork || request.namespace == 'kube-system'")
+	ruledef.CEL.Message = "hostNetwork is only allowed in kube-system"
+	assert.NilError(t, ruledef.Compile())
+	violations = ruledef.Evaluate(in)
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].Message, "hostNetwork is only allowed in kube-system")
+}
+
+func TestCELQuantity(t *testing.T) {
+	pod := `{"spec": {"containers": [
+		{"name": "app", "resources": {"requests": {"memory": "512Mi", "cpu": "500m"}, "limits": {"memory": "1Gi", "cpu": "1"}}},
+		{"name": "sidecar", "resources": {"requests": {"memory": "1Gi", "cpu": 2}, "limits": {"memory": "900Mi", "cpu": "1500m"}}}
+	]}}`
+	in := &Input{Object: pod}
+	valid := []string{
+		"quantity(object.spec.containers[0].resources.limits.memory) >= quantity(object.spec.containers[0].resources.requests.memory)",
+		"quantity(object.spec.containers[0].resources.limits.cpu) >= quantity(object.spec.containers[0].resources.requests.cpu)",
+		"quantity('500m') < quantity('1')",
+		"quantity('1Gi') > quantity('512Mi')",
+		"quantity('1Gi') == quantity(1073741824)",
+		"quantity(object.spec.containers[1].resources.requests.cpu) == 2.0",
+	}
+	for _, expression := range valid {
+		ruledef := celRule(expression)
+		assert.NilError(t, ruledef.Compile(), expression)
+		assert.Equal(t, len(ruledef.Evaluate(in)), 0, expression)
+	}
+
+	ruledef := celRule("object.spec.containers.all(c, quantity(c.resources.limits.memory) >= quantity(c.resources.requests.memory))")
+	assert.NilError(t, ruledef.Compile())
+	violations := ruledef.Evaluate(in)
+	assert.Equal(t, len(violations), 1)
+	assert.Assert(t, !violations[0].EvaluationError)
+
+	ruledef = celRule("quantity('lots') > 1.0")
+	assert.NilError(t, ruledef.Compile())
+	violations = ruledef.Evaluate(in)
+	assert.Equal(t, len(violations), 1)
+	assert.Assert(t, violations[0].EvaluationError)
+}
+
+func TestEvaluateCELAndLIVR(t *testing.T) {
+	ruledef := imageRule("spec.containers.#.image", "")
+	ruledef.CEL = &CELRule{Expression: "object.spec.containers.size() == 1"}
+	violations := ruledef.Evaluate(&Input{Object: meshPod})
+	assert.Equal(t, len(violations), 2)
+}
+
+func TestCompileCEL(t *testing.T) {
+	ruledef := celRule("object.spec.replicas > 1")
+	assert.NilError(t, ruledef.Compile())
+	assert.ErrorContains(t, (&RuleDefinition{CEL: &CELRule{Expression: "object.spec.replicas >"}}).Compile(), "invalid cel.expression")
+	assert.ErrorContains(t, (&RuleDefinition{CEL: &CELRule{Expression: "objects.spec"}}).Compile(), "undeclared reference to 'objects'")
+	assert.Error(t, (&RuleDefinition{CEL: &CELRule{Expression: "1 + 1"}}).Compile(), "cel.expression must return a bool")
+	assert.Error(t, (&RuleDefinition{CEL: &CELRule{}}).Compile(), "cel.expression is empty")
+}
+
+func TestUsesNamespaceObject(t *testing.T) {
+	rl := &RulesList{Rules: []*Rule{{RulesDefinitions: []RuleDefinition{celRule("object.spec.replicas > 1")}}}}
+	assert.Assert(t, !UsesNamespaceObject(rl))
+	rl.Rules[0].RulesDefinitions = append(rl.Rules[0].RulesDefinitions, celRule("has(namespaceObject.metadata.labels)"))
+	assert.Assert(t, UsesNamespaceObject(rl))
+}
+
+func TestCELReadmeExamples(t *testing.T) {
+	//the examples of the README must hold on objects without the optional fields they read
+	examples := []string{
+		"!has(object.spec.hostNetwork) || !object.spec.hostNetwork || request.namespace == 'kube-system'",
+		"object.spec.replicas >= 2 || !has(namespaceObject.metadata.labels) || !has(namespaceObject.metadata.labels.env) || namespaceObject.metadata.labels.env != 'production'",
+		"object.spec.containers.all(c, !has(c.resources) || !has(c.resources.limits) || !has(c.resources.limits.memory) || !has(c.resources.requests) || !has(c.resources.requests.memory) || quantity(c.resources.limits.memory) >= quantity(c.resources.requests.memory))",
+	}
+	objects := []string{
+		`{"kind": "Pod", "spec": {"replicas": 1, "containers": [{"name": "app"}]}}`,
+		`{"kind": "Pod", "spec": {"replicas": 1, "containers": [{"name": "app", "resources": {}}]}}`,
+		`{"kind": "Pod", "spec": {"replicas": 1, "containers": [{"name": "app", "resources": {"limits": {"cpu": "1"}, "requests": {}}}]}}`,
+	}
+	namespaces := []map[string]interface{}{
+		{"metadata": map[string]interface{}{"name": "team-a"}},
+		{"metadata": map[string]interface{}{"name": "team-a", "labels": map[string]interface{}{"tier": "web"}}},
+	}
+	for _, expression := range examples {
+		ruledef := celRule(expression)
+		assert.NilError(t, ruledef.Compile(), expression)
+		for _, object := range objects {
+			for _, namespace := range namespaces {
+				namespace := namespace
+				in := &Input{
+					Object:          object,
+					Request:         map[string]interface{}{"namespace": "team-a", "operation": "CREATE"},
+					NamespaceObject: func() map[string]interface{} { return namespace },
+				}
+				assert.Equal(t, len(ruledef.Evaluate(in)), 0, "%s on %s", expression, object)
+			}
+		}
+	}
+}
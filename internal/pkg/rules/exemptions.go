@@ -0,0 +1,103 @@
+package rules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/grupozap/aegir/internal/pkg/utils"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//Exemption waives the violations of the rules matching Rule for the objects matching Namespace and Name until ExpiresAt.
+//Rule, Namespace and Name are glob patterns, an empty Name matches every object.
+type Exemption struct {
+	Rule      string    `yaml:"rule" json:"rule"`
+	Namespace string    `yaml:"namespace" json:"namespace"`
+	Name      string    `yaml:"name,omitempty" json:"name,omitempty"`
+	Reason    string    `yaml:"reason" json:"reason"`
+	Owner     string    `yaml:"owner" json:"owner"`
+	ExpiresAt time.Time `yaml:"expires_at" json:"expires_at"`
+}
+
+var exemptionStore []*Exemption
+
+//LoadExemptions reads the exemptions of a file holding an exemptions list, like the one of a rules file
+func LoadExemptions(fp string) ([]*Exemption, error) {
+	file, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	var rl RulesList
+	if err := yaml.Unmarshal(file, &rl); err != nil {
+		return nil, err
+	}
+	return rl.Exemptions, nil
+}
+
+//Validate checks that the exemption has every field, exemptions can't be permanent
+func (e *Exemption) Validate() error {
+	switch {
+	case e.Rule == "":
+		return fmt.Errorf("an exemption has no rule")
+	case e.Namespace == "":
+		return fmt.Errorf("exemption of rule %s has no namespace, use \"*\" to apply it to every namespace", e.Rule)
+	case e.Reason == "":
+		return fmt.Errorf("exemption of rule %s in namespace %s has no reason", e.Rule, e.Namespace)
+	case e.Owner == "":
+		return fmt.Errorf("exemption of rule %s in namespace %s has no owner", e.Rule, e.Namespace)
+	case e.ExpiresAt.IsZero():
+		return fmt.Errorf("exemption of rule %s in namespace %s has no expires_at", e.Rule, e.Namespace)
+	}
+	return nil
+}
+
+//Expired reports whether the exemption no longer applies at now
+func (e *Exemption) Expired(now time.Time) bool {
+	return !now.Before(e.ExpiresAt)
+}
+
+//Matches reports whether the exemption applies to a rule and an object, ignoring its expiry
+func (e *Exemption) Matches(rule, namespace, name string) bool {
+	return utils.MatchGlob(e.Rule, rule) && utils.MatchGlob(e.Namespace, namespace) && (e.Name == "" || utils.MatchGlob(e.Name, name))
+}
+
+func (e *Exemption) String() string {
+	name := e.Name
+	if name == "" {
+		name = "*"
+	}
+	return fmt.Sprintf("exemption of rule %s for %s/%s owned by %s", e.Rule, e.Namespace, name, e.Owner)
+}
+
+//BuildExemptionStore validates the exemptions of rl and makes them available to FindExemption
+func BuildExemptionStore(rl *RulesList) {
+	now := time.Now()
+	for _, e := range rl.Exemptions {
+		if err := e.Validate(); err != nil {
+			log.Fatalf("invalid exemption: %v", err)
+		}
+		if e.Expired(now) {
+			log.Printf("WARNING: %s expired at %s, it is ignored", e, e.ExpiresAt.Format(time.RFC3339))
+		}
+	}
+	exemptionStore = rl.Exemptions
+}
+
+//FindExemption returns the first unexpired exemption applying to a rule and an object at now, if any,
+//and the expired exemptions that would have applied
+func FindExemption(rule, namespace, name string, now time.Time) (*Exemption, []*Exemption) {
+	var expired []*Exemption
+	for _, e := range exemptionStore {
+		if !e.Matches(rule, namespace, name) {
+			continue
+		}
+		if e.Expired(now) {
+			expired = append(expired, e)
+			continue
+		}
+		return e, expired
+	}
+	return nil, expired
+}
@@ -0,0 +1,74 @@
+package rules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestLoadExemptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exemptions")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "exemptions.yaml")
+	assert.NilError(t, ioutil.WriteFile(fp, []byte(`
+exemptions:
+- rule: no_latest
+  namespace: payments
+  name: "legacy-*"
+  reason: "Migrating to pinned images"
+  owner: "payments@example.com"
+  expires_at: 2026-12-31
+- rule: "pod-security/*"
+  namespace: monitoring
+  reason: "node-exporter needs the host network"
+  owner: "sre@example.com"
+  expires_at: 2027-01-15T12:00:00Z
+`), 0644))
+	exemptions, err := LoadExemptions(fp)
+	assert.NilError(t, err)
+	assert.Equal(t, len(exemptions), 2)
+	assert.Equal(t, exemptions[0].Name, "legacy-*")
+	assert.Equal(t, exemptions[0].ExpiresAt, time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, exemptions[1].ExpiresAt, time.Date(2027, 1, 15, 12, 0, 0, 0, time.UTC))
+	for _, e := range exemptions {
+		assert.NilError(t, e.Validate())
+	}
+}
+
+func TestFindExemption(t *testing.T) {
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	legacy := &Exemption{Rule: "no_latest", Namespace: "payments", Name: "legacy-*", Reason: "migration", Owner: "payments", ExpiresAt: now.Add(time.Hour)}
+	expired := &Exemption{Rule: "no_latest", Namespace: "*", Reason: "migration", Owner: "platform", ExpiresAt: now.Add(-time.Hour)}
+	pss := &Exemption{Rule: "pod-security/*", Namespace: "monitoring", Reason: "node-exporter", Owner: "sre", ExpiresAt: now.Add(time.Hour)}
+	BuildExemptionStore(&RulesList{Exemptions: []*Exemption{expired, legacy, pss}})
+	defer BuildExemptionStore(&RulesList{})
+
+	e, exp := FindExemption("no_latest", "payments", "legacy-api", now)
+	assert.Equal(t, e, legacy)
+	assert.DeepEqual(t, exp, []*Exemption{expired})
+
+	e, exp = FindExemption("no_latest", "payments", "api", now)
+	assert.Assert(t, e == nil)
+	assert.DeepEqual(t, exp, []*Exemption{expired})
+
+	e, _ = FindExemption("no_latest", "payments", "legacy-api", now.Add(2*time.Hour))
+	assert.Assert(t, e == nil)
+
+	e, exp = FindExemption("pod-security/v1/baseline/host-namespaces", "monitoring", "node-exporter", now)
+	assert.Equal(t, e, pss)
+	assert.Equal(t, len(exp), 0)
+	e, _ = FindExemption("pod-security/v1/baseline/host-namespaces", "payments", "node-exporter", now)
+	assert.Assert(t, e == nil)
+}
+
+func TestValidateExemption(t *testing.T) {
+	e := &Exemption{Rule: "no_latest", Namespace: "payments", Reason: "migration", Owner: "payments"}
+	assert.ErrorContains(t, e.Validate(), "exemption of rule no_latest in namespace payments has no expires_at")
+	e.Owner = ""
+	assert.ErrorContains(t, e.Validate(), "has no owner")
+}
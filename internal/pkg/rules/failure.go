@@ -0,0 +1,52 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/grupozap/aegir/internal/pkg/utils"
+)
+
+const (
+	//FailurePolicyDeny rejects requests when a definition of the rule could not be evaluated
+	FailurePolicyDeny = "deny"
+	//FailurePolicyAllow allows requests when a definition of the rule could not be evaluated, returning a warning to the client
+	FailurePolicyAllow = "allow"
+)
+
+//EvaluationError returns the violation reporting that a definition could not be evaluated, handled by the failure policy of its rule
+func (ruledef *RuleDefinition) EvaluationError(format string, args ...interface{}) *utils.Violation {
+	return &utils.Violation{
+		Description:     ruledef.description(),
+		JSONPath:        ruledef.Field,
+		Message:         fmt.Sprintf(format, args...),
+		EvaluationError: true,
+	}
+}
+
+//description returns the description of the LIVR rule, the CEL expression or the Rego module of the definition
+func (ruledef *RuleDefinition) description() string {
+	switch {
+	case ruledef.LivrRule.Description != "":
+		return ruledef.LivrRule.Description
+	case ruledef.CEL != nil && ruledef.CEL.Description != "":
+		return ruledef.CEL.Description
+	case ruledef.Rego != nil:
+		return ruledef.Rego.Description
+	}
+	return ""
+}
+
+//failurePolicy inherits the failure policy of rl when the rule has none, it defaults to deny
+func (rule *Rule) failurePolicy(rl *RulesList) error {
+	if rule.FailurePolicy == "" {
+		rule.FailurePolicy = rl.FailurePolicy
+	}
+	switch rule.FailurePolicy {
+	case "":
+		rule.FailurePolicy = FailurePolicyDeny
+	case FailurePolicyDeny, FailurePolicyAllow:
+	default:
+		return fmt.Errorf("unknown failure_policy %q, use %q or %q", rule.FailurePolicy, FailurePolicyDeny, FailurePolicyAllow)
+	}
+	return nil
+}
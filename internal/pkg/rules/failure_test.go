@@ -0,0 +1,67 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	livr "github.com/k33nice/go-livr"
+	"gotest.tools/assert"
+)
+
+func TestEvaluationError(t *testing.T) {
+	ruledef := RuleDefinition{
+		Field:    "spec.containers.#.image",
+		LivrRule: RuleObject{Description: "Images must be pinned", RuleObj: livr.Dictionary{"image": "no_such_rule"}},
+	}
+	violations := ruledef.Evaluate(&Input{Object: `{"spec": {"containers": [{"name": "app", "image": "app:1.0"}]}}`})
+	assert.Equal(t, len(violations), 1)
+	assert.Assert(t, violations[0].EvaluationError)
+	assert.Equal(t, violations[0].Description, "Images must be pinned")
+	assert.Assert(t, strings.Contains(violations[0].Message, "no_such_rule"), violations[0].Message)
+	assert.ErrorContains(t, ruledef.Compile(), "invalid livr_rule: Rule no_such_rule not registerd")
+	ruledef.LivrRule.RuleObj = livr.Dictionary{"image": livr.Dictionary{"image_semver": ">= one"}}
+	assert.ErrorContains(t, ruledef.Compile(), `invalid livr_rule: image_semver: invalid constraint ">= one"`)
+
+	ruledef = celRule("object.spec.replicas")
+	violations = ruledef.Evaluate(&Input{Object: limitsPod})
+	assert.Equal(t, len(violations), 1)
+	assert.Assert(t, violations[0].EvaluationError)
+
+	ruledef = celRule("object.spec.replicas > 5")
+	violations = ruledef.Evaluate(&Input{Object: limitsPod})
+	assert.Equal(t, len(violations), 1)
+	assert.Assert(t, !violations[0].EvaluationError)
+}
+
+func TestFailurePolicy(t *testing.T) {
+	rule := &Rule{}
+	assert.NilError(t, rule.failurePolicy(&RulesList{}))
+	assert.Equal(t, rule.FailurePolicy, FailurePolicyDeny)
+
+	rule = &Rule{}
+	assert.NilError(t, rule.failurePolicy(&RulesList{FailurePolicy: FailurePolicyAllow}))
+	assert.Equal(t, rule.FailurePolicy, FailurePolicyAllow)
+
+	rule = &Rule{FailurePolicy: FailurePolicyDeny}
+	assert.NilError(t, rule.failurePolicy(&RulesList{FailurePolicy: FailurePolicyAllow}))
+	assert.Equal(t, rule.FailurePolicy, FailurePolicyDeny)
+
+	assert.ErrorContains(t, (&Rule{FailurePolicy: "ignore"}).failurePolicy(&RulesList{}), `unknown failure_policy "ignore"`)
+}
+
+func TestCompileBuildsLIVRValidatorOnce(t *testing.T) {
+	ruledef := RuleDefinition{
+		Field:    "spec.containers.#.image",
+		LivrRule: RuleObject{Description: "Images must be pinned", RuleObj: livr.Dictionary{"image": livr.Dictionary{"image_tag_not": "latest"}}},
+	}
+	assert.NilError(t, ruledef.Compile())
+	validator := ruledef.validator
+	assert.Assert(t, validator != nil)
+	pod := `{"spec": {"containers": [{"name": "app", "image": "app:1.0"}, {"name": "proxy", "image": "proxy"}]}}`
+	for i := 0; i < 2; i++ {
+		violations := ruledef.Evaluate(&Input{Object: pod})
+		assert.Equal(t, len(violations), 1)
+		assert.Equal(t, violations[0].Element, "proxy")
+	}
+	assert.Equal(t, ruledef.validator, validator)
+}
@@ -0,0 +1,66 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Admission operations a rule can be evaluated on
+const (
+	OperationCreate  = "CREATE"
+	OperationUpdate  = "UPDATE"
+	OperationDelete  = "DELETE"
+	OperationConnect = "CONNECT"
+	//OperationAll applies a rule to every operation
+	OperationAll = "*"
+)
+
+//Operations lists the admission operations in the order they are reported
+var Operations = []string{OperationCreate, OperationUpdate, OperationDelete, OperationConnect}
+
+//DefaultOperations are the operations of rules without operations
+var DefaultOperations = []string{OperationCreate, OperationUpdate}
+
+//OperationTypes returns the operations the rule is evaluated on, in the order of Operations
+func (rule *Rule) OperationTypes() []string {
+	if len(rule.Operations) == 0 {
+		return DefaultOperations
+	}
+	set := map[string]bool{}
+	for _, op := range rule.Operations {
+		op = strings.ToUpper(op)
+		if op == OperationAll {
+			return Operations
+		}
+		set[op] = true
+	}
+	var ops []string
+	for _, op := range Operations {
+		if set[op] {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+//AppliesToOperation reports whether the rule is evaluated for requests of operation, like CREATE
+func (rule *Rule) AppliesToOperation(operation string) bool {
+	for _, op := range rule.OperationTypes() {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+//ValidateOperations checks that every operation of the rule is an admission operation
+func (rule *Rule) ValidateOperations() error {
+	for _, op := range rule.Operations {
+		switch strings.ToUpper(op) {
+		case OperationCreate, OperationUpdate, OperationDelete, OperationConnect, OperationAll:
+		default:
+			return fmt.Errorf("unknown operation %q, use %s or %q", op, strings.Join(Operations, ", "), OperationAll)
+		}
+	}
+	return nil
+}
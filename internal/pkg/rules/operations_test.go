@@ -0,0 +1,25 @@
+package rules
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestOperationTypes(t *testing.T) {
+	assert.DeepEqual(t, (&Rule{}).OperationTypes(), []string{OperationCreate, OperationUpdate})
+	assert.DeepEqual(t, (&Rule{Operations: []string{"delete", "CREATE"}}).OperationTypes(), []string{OperationCreate, OperationDelete})
+	assert.DeepEqual(t, (&Rule{Operations: []string{"CREATE", "*"}}).OperationTypes(), Operations)
+
+	rule := &Rule{Operations: []string{"DELETE"}}
+	assert.Assert(t, rule.AppliesToOperation(OperationDelete))
+	assert.Assert(t, !rule.AppliesToOperation(OperationCreate))
+	assert.Assert(t, (&Rule{}).AppliesToOperation(OperationUpdate))
+	assert.Assert(t, !(&Rule{}).AppliesToOperation(OperationConnect))
+}
+
+func TestValidateOperations(t *testing.T) {
+	assert.NilError(t, (&Rule{}).ValidateOperations())
+	assert.NilError(t, (&Rule{Operations: []string{"create", "DELETE", "*"}}).ValidateOperations())
+	assert.Error(t, (&Rule{Operations: []string{"PATCH"}}).ValidateOperations(), `unknown operation "PATCH", use CREATE, UPDATE, DELETE, CONNECT or "*"`)
+}
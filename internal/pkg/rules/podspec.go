@@ -0,0 +1,150 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grupozap/aegir/internal/pkg/kinds"
+	"github.com/grupozap/aegir/internal/pkg/utils"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//ResourceTypePodSpec applies a rule to every kind holding a pod spec, its fields are relative to the pod spec
+	ResourceTypePodSpec = "PodSpec"
+	//ScopeContainer evaluates a definition for every container of the pod spec, its field is relative to the container
+	ScopeContainer = "container"
+)
+
+//ContainerLists are the lists of containers of a pod spec, container scoped definitions apply to all of them
+var ContainerLists = []string{"containers", "initContainers", "ephemeralContainers"}
+
+//container is an element of one of the container lists of a pod spec
+type container struct {
+	list  string
+	index int
+	name  string
+	value gjson.Result
+}
+
+//decode returns the container as a CEL or Rego value
+func (c *container) decode() interface{} {
+	v, _ := decodeJSON(c.value.Raw)
+	return v
+}
+
+//path returns where the container is in the object, like spec.template.spec.initContainers[0]
+func (c *container) path(podSpec string) string {
+	return fmt.Sprintf("%s.%s[%d]", podSpec, c.list, c.index)
+}
+
+//containers returns the containers of every list of the pod spec at podSpec
+func containers(obj, podSpec string) []*container {
+	var cs []*container
+	for _, list := range ContainerLists {
+		for i, value := range gjson.Get(obj, podSpec+"."+list).Array() {
+			cs = append(cs, &container{list: list, index: i, name: value.Get("name").String(), value: value})
+		}
+	}
+	return cs
+}
+
+//Kinds returns the kinds the rule applies to
+func (rule *Rule) Kinds() []string {
+	if rule.ResourceType == ResourceTypePodSpec {
+		return kinds.PodKinds()
+	}
+	return []string{rule.ResourceType}
+}
+
+//forKinds returns the rule as applied to each of its kinds.
+//A PodSpec rule is copied for every kind holding a pod spec, with its fields prefixed by the path of the pod spec.
+func (rule *Rule) forKinds() []*Rule {
+	if rule.ResourceType != ResourceTypePodSpec {
+		return []*Rule{rule}
+	}
+	var rs []*Rule
+	for _, kind := range rule.Kinds() {
+		r := *rule
+		r.ResourceType = kind
+		r.RulesDefinitions = make([]RuleDefinition, len(rule.RulesDefinitions))
+		for i, ruledef := range rule.RulesDefinitions {
+			r.RulesDefinitions[i] = ruledef.InPodSpec(kind)
+		}
+		rs = append(rs, &r)
+	}
+	return rs
+}
+
+//InPodSpec returns the definition of a PodSpec rule as applied to kind, its field becomes the real path of the field in kind.
+//Fields of definitions scoped to containers stay relative to the container.
+func (ruledef RuleDefinition) InPodSpec(kind string) RuleDefinition {
+	spec, ok := kinds.PodSpecPath(kind)
+	if !ok {
+		return ruledef
+	}
+	ruledef.podSpec = spec
+	if ruledef.Field == "" || ruledef.Scope == ScopeContainer {
+		return ruledef
+	}
+	ruledef.Field = ruledef.withPrefix(spec)
+	return ruledef
+}
+
+//withPrefix returns Field, in its syntax, as a path below prefix, a dot separated path whose indexes are numbers or #
+func (ruledef *RuleDefinition) withPrefix(prefix string) string {
+	if ruledef.Field == "" {
+		return prefix
+	}
+	if ruledef.syntax() != FieldSyntaxJSONPath {
+		return prefix + "." + ruledef.Field
+	}
+	//{.containers[*].image} becomes {.spec.template.spec.containers[*].image}
+	sb := strings.Builder{}
+	for _, part := range strings.Split(prefix, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			sb.WriteString("[" + part + "]")
+		} else if part == "#" {
+			sb.WriteString("[*]")
+		} else {
+			sb.WriteString("." + part)
+		}
+	}
+	expression := jsonPathExpression(ruledef.Field)
+	return "{" + sb.String() + strings.TrimSpace(expression[1:])
+}
+
+//containerViolations evaluates the definition for every container.
+//Violations hold the path of the field in every container of the list, the path inside the violating container and the container.
+func (ruledef *RuleDefinition) containerViolations(in *Input) []*utils.Violation {
+	var violations []*utils.Violation
+	if ruledef.podSpec == "" {
+		return violations
+	}
+	for _, c := range containers(in.Object, ruledef.podSpec) {
+		for _, v := range ruledef.evaluate(in, c) {
+			v.JSONPath = ruledef.withPrefix(ruledef.podSpec + "." + c.list + ".#")
+			v.Path = strings.TrimSuffix(c.path(ruledef.podSpec)+"."+v.Path, ".")
+			if v.Element == "" {
+				v.Element = c.name
+			}
+			v.ContainerList = c.list
+			v.Container = c.name
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+//lookup returns the value at a dot separated path of a decoded JSON document, nil when it doesn't exist
+func lookup(obj interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		obj = m[key]
+	}
+	return obj
+}
@@ -0,0 +1,116 @@
+package rules
+
+import (
+	"testing"
+
+	livr "github.com/k33nice/go-livr"
+	"gotest.tools/assert"
+)
+
+var cronJob = `{
+    "kind": "CronJob",
+    "spec": {"jobTemplate": {"spec": {"template": {"spec": {
+        "hostPID": true,
+        "containers": [{"name": "backup", "image": "backup:latest"}]
+    }}}}}
+}`
+
+func TestInPodSpec(t *testing.T) {
+	ruledef := RuleDefinition{Field: "containers.#.image"}
+	assert.Equal(t, ruledef.InPodSpec("Pod").Field, "spec.containers.#.image")
+	assert.Equal(t, ruledef.InPodSpec("CronJob").Field, "spec.jobTemplate.spec.template.spec.containers.#.image")
+	assert.Equal(t, ruledef.InPodSpec("ConfigMap").Field, "containers.#.image")
+
+	ruledef = RuleDefinition{Field: "containers[*].image", FieldSyntax: FieldSyntaxJSONPath}
+	assert.Equal(t, ruledef.InPodSpec("Deployment").Field, "{.spec.template.spec.containers[*].image}")
+	ruledef.Field = `{ .containers[?(@.name=="app")].image }`
+	assert.Equal(t, ruledef.InPodSpec("Pod").Field, `{.spec.containers[?(@.name=="app")].image }`)
+}
+
+func TestPodSpecRuleForKinds(t *testing.T) {
+	rule := &Rule{Name: "no_latest", ResourceType: ResourceTypePodSpec, RulesDefinitions: []RuleDefinition{imageRule("containers.#.image", "")}}
+	rs := rule.forKinds()
+	assert.Equal(t, len(rs), 8)
+	var kinds []string
+	for _, r := range rs {
+		kinds = append(kinds, r.ResourceType)
+	}
+	assert.DeepEqual(t, kinds, rule.Kinds())
+	assert.Equal(t, rule.RulesDefinitions[0].Field, "containers.#.image")
+
+	for _, r := range rs {
+		if r.ResourceType != "CronJob" {
+			continue
+		}
+		violations := r.RulesDefinitions[0].Evaluate(&Input{Object: cronJob})
+		assert.Equal(t, len(violations), 1)
+		assert.Equal(t, violations[0].JSONPath, "spec.jobTemplate.spec.template.spec.containers.#.image")
+	}
+
+	deployment := &Rule{ResourceType: "Deployment"}
+	rs = deployment.forKinds()
+	assert.Equal(t, len(rs), 1)
+	assert.Equal(t, rs[0], deployment)
+}
+
+func TestPodSpecVariables(t *testing.T) {
+	ruledef := celRule("!has(podSpec.hostPID) || !podSpec.hostPID").InPodSpec("CronJob")
+	assert.NilError(t, ruledef.Compile())
+	assert.Equal(t, len(ruledef.Evaluate(&Input{Object: cronJob})), 1)
+
+	ruledef = celRule("podSpec == null")
+	assert.Equal(t, len(ruledef.Evaluate(&Input{Object: cronJob})), 0)
+
+	ruledef = RuleDefinition{Rego: &RegoRule{Module: "package pods\ndeny[msg] { input.podSpec.hostPID; msg := \"hostPID is not allowed\" }"}}.InPodSpec("CronJob")
+	violations := ruledef.Evaluate(&Input{Object: cronJob})
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].Message, "hostPID is not allowed")
+}
+
+var sidecarDeployment = `{
+    "kind": "Deployment",
+    "spec": {"template": {"spec": {
+        "initContainers": [{"name": "setup", "image": "setup:latest", "securityContext": {"privileged": true}}],
+        "containers": [{"name": "app", "image": "app:1.0"}, {"name": "proxy", "image": "proxy:latest"}],
+        "ephemeralContainers": [{"name": "debugger", "image": "busybox:latest"}]
+    }}}
+}`
+
+func TestContainerScope(t *testing.T) {
+	ruledef := RuleDefinition{
+		Field:    "image",
+		Scope:    ScopeContainer,
+		LivrRule: RuleObject{RuleObj: livr.Dictionary{"image": livr.Dictionary{"not_like": ":latest$"}}},
+	}.InPodSpec("Deployment")
+	assert.NilError(t, ruledef.Compile())
+	violations := ruledef.Evaluate(&Input{Object: sidecarDeployment})
+	var got [][]string
+	for _, v := range violations {
+		got = append(got, []string{v.ContainerList, v.Container, v.JSONPath, v.Path, v.Value().(string)})
+	}
+	assert.DeepEqual(t, got, [][]string{
+		{"containers", "proxy", "spec.template.spec.containers.#.image", "spec.template.spec.containers[1].image", "proxy:latest"},
+		{"initContainers", "setup", "spec.template.spec.initContainers.#.image", "spec.template.spec.initContainers[0].image", "setup:latest"},
+		{"ephemeralContainers", "debugger", "spec.template.spec.ephemeralContainers.#.image", "spec.template.spec.ephemeralContainers[0].image", "busybox:latest"},
+	})
+
+	ruledef = RuleDefinition{
+		Field:       "securityContext.privileged",
+		FieldSyntax: FieldSyntaxJSONPath,
+		Scope:       ScopeContainer,
+		CEL:         &CELRule{Expression: "!has(container.securityContext) || !container.securityContext.privileged"},
+	}.InPodSpec("Deployment")
+	assert.NilError(t, ruledef.Compile())
+	violations = ruledef.Evaluate(&Input{Object: sidecarDeployment})
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].Container, "setup")
+	assert.Equal(t, violations[0].JSONPath, "{.spec.template.spec.initContainers[*].securityContext.privileged}")
+	assert.Equal(t, violations[0].Path, "spec.template.spec.initContainers[0]")
+	assert.Equal(t, violations[0].Element, "setup")
+
+	ruledef = RuleDefinition{Scope: ScopeContainer, CEL: &CELRule{Expression: "true"}}
+	assert.Equal(t, len(ruledef.Evaluate(&Input{Object: sidecarDeployment})), 0)
+
+	ruledef = RuleDefinition{Field: "image", Scope: "pod"}
+	assert.ErrorContains(t, ruledef.Compile(), `unknown scope "pod", use "container" or leave it unset`)
+}
@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grupozap/aegir/internal/pkg/utils"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+//RegoRule is a rule definition written as a Rego module. Every element of its deny or violation
+//set is a violation, either a message or an object with a msg field, like the policies of
+//Gatekeeper and kube-mgmt. The input holds the admission request as input.request and input.review.
+type RegoRule struct {
+	Description string `yaml:"description"`
+	Module      string `yaml:"module"`
+}
+
+//regoQuery collects both sets, a set that is not defined by the module is empty
+const regoQuery = "deny = [d | d := data.%[1]s.deny[_]]; violation = [v | v := data.%[1]s.violation[_]]"
+
+//compileRego parses the module and prepares the query of its deny and violation sets
+func compileRego(module string) (*rego.PreparedEvalQuery, error) {
+	if strings.TrimSpace(module) == "" {
+		return nil, fmt.Errorf("rego.module is empty")
+	}
+	parsed, err := ast.ParseModule("module.rego", module)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rego.module: %v", err)
+	}
+	defined := false
+	for _, r := range parsed.Rules {
+		if n := r.Head.Name.String(); n == "deny" || n == "violation" {
+			defined = true
+		}
+	}
+	if !defined {
+		return nil, fmt.Errorf("rego.module must define deny or violation")
+	}
+	pkg := strings.TrimPrefix(parsed.Package.Path.String(), "data.")
+	query, err := rego.New(
+		rego.ParsedModule(parsed),
+		rego.Query(fmt.Sprintf(regoQuery, pkg)),
+	).PrepareForEval(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("invalid rego.module: %v", err)
+	}
+	return &query, nil
+}
+
+//regoInput returns the input document of Rego modules, an admission request holding the objects
+func (in *Input) regoInput() (map[string]interface{}, error) {
+	request := map[string]interface{}{}
+	for k, v := range in.Request {
+		request[k] = v
+	}
+	object, err := decodeJSON(in.Object)
+	if err != nil {
+		return nil, err
+	}
+	oldObject, err := decodeJSON(in.OldObject)
+	if err != nil {
+		return nil, err
+	}
+	request["object"] = object
+	request["oldObject"] = oldObject
+	return map[string]interface{}{"request": request, "review": request}, nil
+}
+
+func (ruledef *RuleDefinition) regoViolations(in *Input, c *container) []*utils.Violation {
+	violation := func(field, msg string) *utils.Violation {
+		return &utils.Violation{
+			Description: ruledef.Rego.Description,
+			JSONPath:    field,
+			Message:     msg,
+		}
+	}
+	failure := func(format string, args ...interface{}) []*utils.Violation {
+		v := ruledef.EvaluationError(format, args...)
+		v.Description = ruledef.Rego.Description
+		return []*utils.Violation{v}
+	}
+	query := ruledef.query
+	if query == nil {
+		var err error
+		if query, err = compileRego(ruledef.Rego.Module); err != nil {
+			return failure("%v", err)
+		}
+	}
+	input, err := in.regoInput()
+	if err != nil {
+		return failure("could not decode the object: %v", err)
+	}
+	if ruledef.podSpec != "" {
+		input["podSpec"] = lookup(input["request"].(map[string]interface{})["object"], ruledef.podSpec)
+	}
+	if c != nil {
+		input["container"] = c.decode()
+	}
+	rs, err := query.Eval(context.Background(), rego.EvalInput(input))
+	if err != nil {
+		return failure("Rego module could not be evaluated: %v", err)
+	}
+
+	var violations []*utils.Violation
+	for _, r := range rs {
+		for _, set := range []string{"deny", "violation"} {
+			elements, _ := r.Bindings[set].([]interface{})
+			for _, e := range elements {
+				field, msg := ruledef.Field, ""
+				switch d := e.(type) {
+				case string:
+					msg = d
+				case map[string]interface{}:
+					if m, ok := d["msg"].(string); ok {
+						msg = m
+					} else if m, ok := d["message"].(string); ok {
+						msg = m
+					}
+					if f, ok := d["field"].(string); ok {
+						field = f
+					}
+				}
+				if msg == "" {
+					msg = fmt.Sprintf("%s: %v", set, e)
+				}
+				violations = append(violations, violation(field, msg))
+			}
+		}
+	}
+	return violations
+}
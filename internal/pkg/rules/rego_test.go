@@ -0,0 +1,46 @@
+package rules
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+const latestTagModule = `
+package k8s.images
+
+deny[msg] {
+	container := input.request.object.spec.containers[_]
+	endswith(container.image, ":latest")
+	msg := sprintf("container %v uses the latest tag", [container.name])
+}
+
+violation[{"msg": msg, "field": "spec.hostNetwork"}] {
+	input.review.object.spec.hostNetwork
+	input.review.namespace != "kube-system"
+	msg := "hostNetwork is reserved to kube-system"
+}
+`
+
+func TestEvaluateRego(t *testing.T) {
+	ruledef := RuleDefinition{Rego: &RegoRule{Description: "images", Module: latestTagModule}}
+	assert.NilError(t, ruledef.Compile())
+
+	violations := ruledef.Evaluate(&Input{Object: meshPod, Request: map[string]interface{}{"namespace": "team-a"}})
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].Description, "images")
+	assert.Equal(t, violations[0].Message, "container istio-proxy uses the latest tag")
+
+	violations = ruledef.Evaluate(&Input{Object: limitsPod, Request: map[string]interface{}{"namespace": "team-a"}})
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].JSONPath, "spec.hostNetwork")
+	assert.Equal(t, violations[0].Message, "hostNetwork is reserved to kube-system")
+
+	assert.Equal(t, len(ruledef.Evaluate(&Input{Object: limitsPod, Request: map[string]interface{}{"namespace": "kube-system"}})), 0)
+}
+
+func TestCompileRego(t *testing.T) {
+	assert.ErrorContains(t, (&RuleDefinition{Rego: &RegoRule{Module: "package x\ndeny[msg] {"}}).Compile(), "invalid rego.module")
+	assert.Error(t, (&RuleDefinition{Rego: &RegoRule{Module: "package x\nallow = true"}}).Compile(), "rego.module must define deny or violation")
+	assert.Error(t, (&RuleDefinition{Rego: &RegoRule{}}).Compile(), "rego.module is empty")
+}
@@ -5,30 +5,89 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"sync"
+	"text/template"
 
 	y2j "github.com/ghodss/yaml"
+	"github.com/google/cel-go/cel"
+	"github.com/grupozap/aegir/internal/pkg/kinds"
+	"github.com/grupozap/aegir/internal/pkg/messages"
 	"github.com/grupozap/aegir/internal/pkg/utils"
+	"github.com/grupozap/aegir/pkg/customrules"
 	livr "github.com/k33nice/go-livr"
+	"github.com/open-policy-agent/opa/rego"
 	"github.com/tidwall/gjson"
 	yaml "gopkg.in/yaml.v2"
 )
 
+const (
+	//EnforcementDeny rejects requests that violate the rule
+	EnforcementDeny = "deny"
+	//EnforcementWarn allows requests that violate the rule, returning warnings to the client
+	EnforcementWarn = "warn"
+)
+
 type RulesList struct {
-	Rules []*Rule `yaml:"rules"`
+	Rules                []*Rule           `yaml:"rules"`
+	MessageTemplate      string            `yaml:"message_template,omitempty"`
+	NotificationTemplate string            `yaml:"notification_template,omitempty"`
+	RunbookURL           string            `yaml:"runbook_url,omitempty"`
+	PodSecurity          []PodSecurity     `yaml:"pod_security,omitempty"`
+	Language             string            `yaml:"language,omitempty"`
+	ErrorMessages        map[string]string `yaml:"error_messages,omitempty"`
+	Exemptions           []*Exemption      `yaml:"exemptions,omitempty"`
+	FailurePolicy        string            `yaml:"failure_policy,omitempty"`
+}
+
+//PodSecurity enables a level of the bundled Pod Security Standards rule pack in a namespace
+type PodSecurity struct {
+	Namespace                string `yaml:"namespace"`
+	Level                    string `yaml:"level"`
+	Version                  string `yaml:"version,omitempty"`
+	EnforcementMode          string `yaml:"enforcement_mode,omitempty"`
+	SlackNotificationChannel string `yaml:"slack_notification_channel,omitempty"`
 }
 
 type Rule struct {
-	Name                     string           `yaml:"name"`
-	Namespace                string           `yaml:"namespace"`
-	ResourceType             string           `yaml:"resource_type"`
-	RulesDefinitions         []RuleDefinition `yaml:"rules_definitions"`
-	SlackNotificationChannel string           `yaml:"slack_notification_channel,omitempty"`
+	Name                     string            `yaml:"name"`
+	Namespace                string            `yaml:"namespace"`
+	ResourceType             string            `yaml:"resource_type"`
+	RulesDefinitions         []RuleDefinition  `yaml:"rules_definitions"`
+	SlackNotificationChannel string            `yaml:"slack_notification_channel,omitempty"`
+	MessageTemplate          string            `yaml:"message_template,omitempty"`
+	NotificationTemplate     string            `yaml:"notification_template,omitempty"`
+	RunbookURL               string            `yaml:"runbook_url,omitempty"`
+	RemediationURL           string            `yaml:"remediation_url,omitempty"`
+	EnforcementMode          string            `yaml:"enforcement_mode,omitempty"`
+	ErrorMessages            map[string]string `yaml:"error_messages,omitempty"`
+	MatchUsers               []string          `yaml:"match_users,omitempty"`
+	ExcludeUsers             []string          `yaml:"exclude_users,omitempty"`
+	ExcludeGroups            []string          `yaml:"exclude_groups,omitempty"`
+	ExcludeServiceAccounts   []string          `yaml:"exclude_service_accounts,omitempty"`
+	Operations               []string          `yaml:"operations,omitempty"`
+	FailurePolicy            string            `yaml:"failure_policy,omitempty"`
+
+	messageTmpl      *template.Template
+	notificationTmpl *template.Template
 }
 
 type RuleDefinition struct {
-	Field           string     `yaml:"field"`
-	FieldIsOptional bool       `yaml:"field_is_optional"`
-	LivrRule        RuleObject `yaml:"livr_rule"`
+	Field           string            `yaml:"field"`
+	FieldSyntax     string            `yaml:"field_syntax,omitempty"`
+	FieldIsOptional bool              `yaml:"field_is_optional"`
+	Scope           string            `yaml:"scope,omitempty"`
+	Bind            map[string]string `yaml:"bind,omitempty"`
+	LivrRule        RuleObject        `yaml:"livr_rule"`
+	CEL             *CELRule          `yaml:"cel,omitempty"`
+	Rego            *RegoRule         `yaml:"rego,omitempty"`
+
+	program   cel.Program
+	query     *rego.PreparedEvalQuery
+	validator *livrValidator
+	//podSpec is the path of the pod spec of the kind a PodSpec rule is applied to
+	podSpec string
+	//catalogue translates the LIVR error codes of violations
+	catalogue messages.Catalogue
 }
 
 type RuleObject struct {
@@ -37,7 +96,7 @@ type RuleObject struct {
 }
 
 type Resource struct {
-	Kind     map[string]interface{} `json:"kind"`
+	Kind     string                 `json:"kind"`
 	Metadata map[string]interface{} `json:"metadata"`
 	Spec     map[string]interface{} `json:"spec"`
 }
@@ -63,31 +122,188 @@ func createKey(ns, rt string) string {
 
 func BuildRuleStore(rl *RulesList) {
 	for _, rule := range rl.Rules {
-		k := createKey(rule.Namespace, rule.ResourceType)
-		if _, ok := ruleStore[k]; !ok {
-			ruleStore[k] = []*Rule{}
+		if err := rule.compileTemplates(rl); err != nil {
+			log.Fatalf("could not parse templates of rule %s: %v", rule.Name, err)
+		}
+		catalogue, err := rule.catalogue(rl)
+		if err != nil {
+			log.Fatalf("could not load the error messages of rule %s: %v", rule.Name, err)
+		}
+		switch rule.EnforcementMode {
+		case "":
+			rule.EnforcementMode = EnforcementDeny
+		case EnforcementDeny, EnforcementWarn:
+		default:
+			log.Fatalf("rule %s has an unknown enforcement_mode %q, use %q or %q", rule.Name, rule.EnforcementMode, EnforcementDeny, EnforcementWarn)
+		}
+		if err := rule.failurePolicy(rl); err != nil {
+			log.Fatalf("rule %s has an %v", rule.Name, err)
+		}
+		if err := rule.ValidateOperations(); err != nil {
+			log.Fatalf("rule %s has an %v", rule.Name, err)
+		}
+		for i := range rule.RulesDefinitions {
+			if err := rule.RulesDefinitions[i].Compile(); err != nil {
+				log.Fatalf("rule %s has an invalid rule definition: %v", rule.Name, err)
+			}
+			rule.RulesDefinitions[i].podSpec, _ = kinds.PodSpecPath(rule.ResourceType)
+			rule.RulesDefinitions[i].catalogue = catalogue
+		}
+		if rule.RemediationURL == "" {
+			rule.RemediationURL = rule.RunbookURL
+		}
+		for _, r := range rule.forKinds() {
+			k := createKey(r.Namespace, r.ResourceType)
+			if _, ok := ruleStore[k]; !ok {
+				ruleStore[k] = []*Rule{}
+			}
+			ruleStore[k] = append(ruleStore[k], r)
 		}
-		ruleStore[k] = append(ruleStore[k], rule)
 	}
 }
 
-func (ruledef *RuleDefinition) registerRule() *livr.Validator {
+//compileTemplates parses the rule's templates, inheriting the global ones from rl when unset
+func (rule *Rule) compileTemplates(rl *RulesList) error {
+	if rule.MessageTemplate == "" {
+		rule.MessageTemplate = rl.MessageTemplate
+	}
+	if rule.NotificationTemplate == "" {
+		rule.NotificationTemplate = rl.NotificationTemplate
+	}
+	if rule.RunbookURL == "" {
+		rule.RunbookURL = rl.RunbookURL
+	}
+	var err error
+	if rule.MessageTemplate != "" {
+		if rule.messageTmpl, err = messages.Parse(rule.Name+"/message", rule.MessageTemplate); err != nil {
+			return err
+		}
+	}
+	if rule.NotificationTemplate != "" {
+		if rule.notificationTmpl, err = messages.Parse(rule.Name+"/notification", rule.NotificationTemplate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//catalogue returns the catalogue of the language of rl, with the error messages of rl overridden by the ones of the rule
+func (rule *Rule) catalogue(rl *RulesList) (messages.Catalogue, error) {
+	overrides := make(map[string]string, len(rl.ErrorMessages)+len(rule.ErrorMessages))
+	for code, sentence := range rl.ErrorMessages {
+		overrides[code] = sentence
+	}
+	for code, sentence := range rule.ErrorMessages {
+		overrides[code] = sentence
+	}
+	return messages.NewCatalogue(rl.Language, overrides)
+}
+
+//RenderMessage renders the text returned to the API server for a violation of this rule
+func (rule *Rule) RenderMessage(d messages.Data) (string, error) {
+	d.RunbookURL = rule.RunbookURL
+	return messages.RenderMessage(rule.messageTmpl, d)
+}
+
+//RenderNotification renders the text sent as notification for a violation of this rule
+func (rule *Rule) RenderNotification(d messages.Data) (string, error) {
+	d.RunbookURL = rule.RunbookURL
+	return messages.RenderNotification(rule.notificationTmpl, d)
+}
+
+//livrValidator is the LIVR validator of a definition, shared by every request.
+//A LIVR validator keeps the errors of its last validation, so validations are serialized.
+type livrValidator struct {
+	mu        sync.Mutex
+	validator *livr.Validator
+}
+
+//validate returns the errors of data, nil when it is valid
+func (l *livrValidator) validate(data livr.Dictionary) livr.Dictionary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.validator.Validate(data); err != nil {
+		return l.validator.Errors()
+	}
+	return nil
+}
+
+//registerRule builds the LIVR validator of the definition, failing on rules that are not registered
+func (ruledef *RuleDefinition) registerRule() (validator *livrValidator, err error) {
 	var rule map[string]interface{}
 	r, _ := yaml.Marshal(ruledef.LivrRule.RuleObj)
 	j, err := y2j.YAMLToJSON(r)
 	if err != nil {
-		log.Fatalf("something went wrong when converting YAML to JSON, error: %v", err)
+		return nil, fmt.Errorf("something went wrong when converting YAML to JSON, error: %v", err)
 	}
 	err = json.Unmarshal(j, &rule)
 	if err != nil {
-		log.Fatalf("something went wrong unmarshaling JSON to LIVR: %s", err)
+		return nil, fmt.Errorf("something went wrong unmarshaling JSON to LIVR: %s", err)
+	}
+	v := customrules.NewValidator(rule)
+	//LIVR builds the rules on the first validation and panics on unknown ones
+	defer func() {
+		if r := recover(); r != nil {
+			validator, err = nil, fmt.Errorf("invalid livr_rule: %v", r)
+		}
+	}()
+	v.Validate(livr.Dictionary{})
+	return &livrValidator{validator: v}, nil
+}
+
+//livrRule returns the LIVR validator built by Compile, definitions that were not compiled get a new one
+func (ruledef *RuleDefinition) livrRule() (*livrValidator, error) {
+	if ruledef.validator != nil {
+		return ruledef.validator, nil
+	}
+	return ruledef.registerRule()
+}
+
+//UsesLIVR reports whether the definition has a LIVR rule, definitions without a CEL expression or a Rego module always do
+func (ruledef *RuleDefinition) UsesLIVR() bool {
+	return (ruledef.CEL == nil && ruledef.Rego == nil) || len(ruledef.LivrRule.RuleObj) > 0
+}
+
+//Evaluate returns the violations of the LIVR rule, the CEL expression and the Rego module of the definition.
+//Definitions scoped to containers are evaluated for each container of the pod spec.
+//A definition that could not be evaluated, even by panicking, returns an EvaluationError.
+func (ruledef *RuleDefinition) Evaluate(in *Input) (violations []*utils.Violation) {
+	defer func() {
+		if err := recover(); err != nil {
+			violations = []*utils.Violation{ruledef.EvaluationError("Field: %s could not be evaluated: %v", ruledef.Field, err)}
+		}
+	}()
+	if ruledef.Scope == ScopeContainer {
+		return ruledef.containerViolations(in)
+	}
+	return ruledef.evaluate(in, nil)
+}
+
+//evaluate returns the violations of the definition, LIVR validates the container instead of the object when there is one
+func (ruledef *RuleDefinition) evaluate(in *Input, c *container) []*utils.Violation {
+	var violations []*utils.Violation
+	if ruledef.UsesLIVR() {
+		obj := in.Object
+		if c != nil {
+			obj = c.value.Raw
+		}
+		violations = ruledef.GetViolations(obj)
 	}
-	return livr.New(&livr.Options{LivrRules: rule})
+	if ruledef.CEL != nil {
+		violations = append(violations, ruledef.celViolations(in, c)...)
+	}
+	if ruledef.Rego != nil {
+		violations = append(violations, ruledef.regoViolations(in, c)...)
+	}
+	return violations
 }
 
 func (ruledef *RuleDefinition) GetViolations(obj string) []*utils.Violation {
 	violations := make([]*utils.Violation, 0)
-	jp := gjson.Get(obj, ruledef.Field)
+	jp, elements, err := ruledef.elements(obj)
+	if err != nil {
+		return append(violations, ruledef.EvaluationError("Field: %s could not be evaluated: %v", ruledef.Field, err))
+	}
 	//If field is optional, don't check if it exists.
 	if !ruledef.FieldIsOptional {
 		if !jp.Exists() || (jp.IsArray() && len(jp.Array()) == 0) {
@@ -99,18 +315,26 @@ func (ruledef *RuleDefinition) GetViolations(obj string) []*utils.Violation {
 			violations = append(violations, fieldNotFound)
 		}
 	}
-	for _, jsonobj := range GetJSONObjectByPath(obj, ruledef.Field) {
-		validator := ruledef.registerRule()
-		lastfield := utils.GetLastField(ruledef.Field)
-		objmap := make(map[string]interface{})
-		objmap[lastfield] = jsonobj.Value()
-		_, err := validator.Validate(objmap)
-		if err != nil {
+	if len(elements) == 0 {
+		return violations
+	}
+	validator, err := ruledef.livrRule()
+	if err != nil {
+		return append(violations, ruledef.EvaluationError("Field: %s could not be evaluated: %v", ruledef.Field, err))
+	}
+	for _, e := range elements {
+		objmap := ruledef.values(e.value)
+		if errs := validator.validate(objmap); errs != nil {
+			codes := messages.Codes(errs)
 			v := &utils.Violation{
 				Description: ruledef.LivrRule.Description,
 				JSONPath:    ruledef.Field,
+				Path:        utils.FormatPath(e.path),
+				Element:     elementName(obj, e.path),
 				Object:      objmap,
-				Message:     err.Error(),
+				Actual:      e.value.Value(),
+				Errors:      codes,
+				Message:     ruledef.catalogue.Explain(codes),
 			}
 			violations = append(violations, v)
 		}
@@ -118,23 +342,33 @@ func (ruledef *RuleDefinition) GetViolations(obj string) []*utils.Violation {
 	return violations
 }
 
+//values returns what the LIVR rule validates for an element matched by Field:
+//the element keyed by the last field of the path, or the value of each bound path
+func (ruledef *RuleDefinition) values(element gjson.Result) map[string]interface{} {
+	objmap := make(map[string]interface{})
+	if len(ruledef.Bind) == 0 {
+		objmap[utils.GetLastField(ruledef.Field)] = element.Value()
+		return objmap
+	}
+	for name, path := range ruledef.Bind {
+		if v := element.Get(path); v.Exists() {
+			objmap[name] = v.Value()
+		}
+	}
+	return objmap
+}
+
+//GetJSONObjectByPath returns the values a gjson path points to in Obj, arrays hold one object per element
 func GetJSONObjectByPath(Obj, JSONPath string) []gjson.Result {
-	Objects := make([]gjson.Result, 0)
-	result := gjson.Get(Obj, JSONPath)
-	if result.IsArray() {
-		result.ForEach(func(key, value gjson.Result) bool {
-			if value.IsArray() {
-				Objects = append(Objects, value.Array()...)
-			} else {
-				Objects = append(Objects, value)
-			}
-			return true
-		})
-	} else if result.Exists() {
-		Objects = append(Objects, result)
-		return Objects
+	return valuesOf(match(Obj, JSONPath))
+}
+
+//objects flattens the result of a path, arrays hold one object per element
+func objects(result gjson.Result) []gjson.Result {
+	if !result.Exists() {
+		return valuesOf(nil)
 	}
-	return Objects
+	return valuesOf(flatten("", result))
 }
 
 func GetRules(ns, rt string) []*Rule {
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/grupozap/aegir/internal/pkg/messages"
 	"github.com/grupozap/aegir/internal/pkg/utils"
+	livr "github.com/k33nice/go-livr"
 	"gotest.tools/assert"
 )
 
@@ -536,8 +538,12 @@ func TestGetViolationsFoundViolations(t *testing.T) {
 	violation := &utils.Violation{
 		Description: "Container name must be skull",
 		JSONPath:    "spec.containers.#.name",
+		Path:        "spec.containers[0].name",
+		Element:     "authnetes",
 		Object:      map[string]interface{}{"name": string("authnetes")},
-		Message:     fmt.Sprint("validation error"),
+		Actual:      "authnetes",
+		Errors:      map[string]interface{}{"name": "NOT_ALLOWED_VALUE"},
+		Message:     "name has a value that is not allowed",
 	}
 	for _, rule := range rulesloaded.Rules {
 		v := rule.RulesDefinitions[0].GetViolations(test_pod)
@@ -570,3 +576,51 @@ func TestGetViolationsFieldIsRequired(t *testing.T) {
 		assert.DeepEqual(t, v[0], violation)
 	}
 }
+
+func TestCompileTemplatesInheritsGlobals(t *testing.T) {
+	rl := &RulesList{
+		MessageTemplate: "{{.RuleName}} violated, see {{.RunbookURL}}",
+		RunbookURL:      "https://runbooks.example.com",
+	}
+	rule := &Rule{Name: "ha_only", NotificationTemplate: "*{{.RuleName}}* in {{.Namespace}}"}
+	if err := rule.compileTemplates(rl); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d := messages.Data{RuleName: "ha_only", Namespace: "platform"}
+	msg, _ := rule.RenderMessage(d)
+	assert.Equal(t, msg, "ha_only violated, see https://runbooks.example.com")
+	notification, _ := rule.RenderNotification(d)
+	assert.Equal(t, notification, "*ha_only* in platform")
+}
+
+func TestCompileTemplatesInvalid(t *testing.T) {
+	rule := &Rule{Name: "broken", MessageTemplate: "{{.RuleName"}
+	if err := rule.compileTemplates(&RulesList{}); err == nil {
+		t.Error("expected an error parsing an invalid template")
+	}
+}
+
+func TestCatalogueOverrides(t *testing.T) {
+	rl := &RulesList{Language: "pt-BR", ErrorMessages: map[string]string{"TOO_HIGH": "passa do limite"}}
+	rule := &Rule{Name: "replicas", ErrorMessages: map[string]string{"replicas.TOO_LOW": "precisa de pelo menos 2 réplicas"}}
+	catalogue, err := rule.catalogue(rl)
+	assert.NilError(t, err)
+
+	ruledef := RuleDefinition{
+		Field:     "spec.replicas",
+		LivrRule:  RuleObject{RuleObj: livr.Dictionary{"replicas": livr.Dictionary{"number_between": []interface{}{2, 10}}}},
+		catalogue: catalogue,
+	}
+	for obj, expected := range map[string]string{
+		`{"spec": {"replicas": 1}}`:   "replicas precisa de pelo menos 2 réplicas",
+		`{"spec": {"replicas": 20}}`:  "replicas passa do limite",
+		`{"spec": {"replicas": "a"}}`: "replicas não é um número",
+	} {
+		violations := ruledef.GetViolations(obj)
+		assert.Equal(t, len(violations), 1)
+		assert.Equal(t, violations[0].Message, expected)
+	}
+
+	_, err = rule.catalogue(&RulesList{Language: "klingon"})
+	assert.ErrorContains(t, err, `unknown language "klingon", use one of en, pt`)
+}
@@ -0,0 +1,448 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const (
+	//FieldSyntaxGJSON selects values with gjson paths, including queries like containers.#(name!="istio-proxy")#.image
+	FieldSyntaxGJSON = "gjson"
+	//FieldSyntaxJSONPath selects values with the Kubernetes JSONPath used by kubectl, like {.spec.containers[?(@.name!="istio-proxy")].image}
+	FieldSyntaxJSONPath = "jsonpath"
+)
+
+//syntax returns the syntax of Field, gjson when field_syntax is unset
+func (ruledef *RuleDefinition) syntax() string {
+	if ruledef.FieldSyntax == "" {
+		return FieldSyntaxGJSON
+	}
+	return ruledef.FieldSyntax
+}
+
+//jsonPathExpression wraps a relaxed JSONPath, like spec.replicas, into a template accepted by the jsonpath package
+func jsonPathExpression(field string) string {
+	field = strings.TrimSpace(field)
+	if strings.HasPrefix(field, "{") {
+		return field
+	}
+	if !strings.HasPrefix(field, ".") && !strings.HasPrefix(field, "[") {
+		field = "." + field
+	}
+	return "{" + field + "}"
+}
+
+//Compile checks that Field is a valid expression of its field_syntax and builds the LIVR validator, the CEL expression and the Rego module, if any
+func (ruledef *RuleDefinition) Compile() error {
+	switch ruledef.syntax() {
+	case FieldSyntaxGJSON:
+	case FieldSyntaxJSONPath:
+		if ruledef.Field != "" {
+			if _, err := ruledef.Path(); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown field_syntax %q, use %q or %q", ruledef.FieldSyntax, FieldSyntaxGJSON, FieldSyntaxJSONPath)
+	}
+	if ruledef.Scope != "" && ruledef.Scope != ScopeContainer {
+		return fmt.Errorf("unknown scope %q, use %q or leave it unset", ruledef.Scope, ScopeContainer)
+	}
+	for name, path := range ruledef.Bind {
+		if name == "" || path == "" {
+			return fmt.Errorf("bind %q must have a name and a path", name)
+		}
+	}
+	if ruledef.UsesLIVR() {
+		validator, err := ruledef.registerRule()
+		if err != nil {
+			return err
+		}
+		ruledef.validator = validator
+	}
+	if ruledef.CEL != nil {
+		program, err := compileCEL(ruledef.CEL.Expression)
+		if err != nil {
+			return err
+		}
+		ruledef.program = program
+	}
+	if ruledef.Rego != nil {
+		query, err := compileRego(ruledef.Rego.Module)
+		if err != nil {
+			return err
+		}
+		ruledef.query = query
+	}
+	return nil
+}
+
+//Path returns Field as a gjson path without queries, to be checked against the schema of a kind.
+//Filters and indexes of a JSONPath become '#', wildcards and recursive descents become '*'.
+func (ruledef *RuleDefinition) Path() (string, error) {
+	if ruledef.syntax() != FieldSyntaxJSONPath {
+		return ruledef.Field, nil
+	}
+	nodes, err := jsonPathNodes(ruledef.Field, jsonPathExpression(ruledef.Field))
+	if err != nil {
+		return "", err
+	}
+	if len(nodes) == 0 {
+		return "", fmt.Errorf("field %q is not a JSONPath expression", ruledef.Field)
+	}
+	parts := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		switch node := n.(type) {
+		case *jsonpath.FieldNode:
+			if node.Value != "" {
+				parts = append(parts, strings.ReplaceAll(node.Value, ".", `\.`))
+			}
+		case *jsonpath.ArrayNode, *jsonpath.FilterNode:
+			parts = append(parts, "#")
+		case *jsonpath.WildcardNode, *jsonpath.RecursiveNode, *jsonpath.UnionNode:
+			parts = append(parts, "*")
+		default:
+			return "", fmt.Errorf("unsupported JSONPath expression %q in field %q", n, ruledef.Field)
+		}
+	}
+	return strings.Join(parts, "."), nil
+}
+
+//jsonPathNodes returns the nodes of a JSONPath expression, it must hold a single one
+func jsonPathNodes(name, expression string) ([]jsonpath.Node, error) {
+	parser, err := jsonpath.Parse(name, expression)
+	if err != nil {
+		return nil, err
+	}
+	var list *jsonpath.ListNode
+	for _, n := range parser.Root.Nodes {
+		if l, ok := n.(*jsonpath.ListNode); ok {
+			if list != nil {
+				return nil, fmt.Errorf("field %q must hold a single JSONPath expression", name)
+			}
+			list = l
+		}
+	}
+	if list == nil {
+		return nil, nil
+	}
+	return list.Nodes, nil
+}
+
+//findJSONPath returns the values a JSONPath expression matches in data
+func findJSONPath(name, expression string, data interface{}) ([]reflect.Value, error) {
+	//JSONPath keeps evaluation state, a new one is needed for every object
+	jp := jsonpath.New(name).AllowMissingKeys(true)
+	if err := jp.Parse(expression); err != nil {
+		return nil, err
+	}
+	results, err := jp.FindResults(data)
+	if err != nil {
+		return nil, err
+	}
+	var values []reflect.Value
+	for _, rs := range results {
+		values = append(values, rs...)
+	}
+	return values, nil
+}
+
+//selectField returns the value Field points to in obj, multiple matches are returned as an array
+func (ruledef *RuleDefinition) selectField(obj string) (gjson.Result, error) {
+	if ruledef.syntax() != FieldSyntaxJSONPath {
+		return gjson.Get(obj, ruledef.Field), nil
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(obj), &data); err != nil {
+		return gjson.Result{}, err
+	}
+	results, err := findJSONPath(ruledef.Field, jsonPathExpression(ruledef.Field), data)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	var values []interface{}
+	for _, r := range results {
+		values = append(values, r.Interface())
+	}
+	switch len(values) {
+	case 0:
+		return gjson.Result{}, nil
+	case 1:
+		b, err := json.Marshal(values[0])
+		if err != nil {
+			return gjson.Result{}, err
+		}
+		return gjson.ParseBytes(b), nil
+	}
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(values); err != nil {
+		return gjson.Result{}, err
+	}
+	return gjson.ParseBytes(b.Bytes()), nil
+}
+
+//element is a value matched by Field, path is where it is in the object and is empty when it can't be known
+type element struct {
+	path  string
+	value gjson.Result
+}
+
+//elements returns the value Field points to in obj and its elements, flattened like GetJSONObjectByPath with the path of each of them.
+//Every value LIVR validates is extracted here, whatever the syntax of Field.
+func (ruledef *RuleDefinition) elements(obj string) (gjson.Result, []element, error) {
+	result, err := ruledef.selectField(obj)
+	if err != nil || !result.Exists() {
+		return result, nil, err
+	}
+	if ruledef.syntax() == FieldSyntaxJSONPath {
+		if es, ok := ruledef.jsonPathElements(obj); ok {
+			return result, es, nil
+		}
+		return result, flatten("", result), nil
+	}
+	return result, match(obj, ruledef.Field), nil
+}
+
+//jsonPathElements returns the elements a JSONPath Field matches in obj with their paths. The jsonpath package doesn't tell
+//where it finds values: the expression is evaluated without its trailing fields, the objects and arrays it matches are found
+//in obj by identity and the trailing fields are appended to their paths. It returns false when the paths can't be known,
+//like for values matched by a recursive descent.
+func (ruledef *RuleDefinition) jsonPathElements(obj string) ([]element, bool) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(obj), &data); err != nil {
+		return nil, false
+	}
+	expression := jsonPathExpression(ruledef.Field)
+	nodes, err := jsonPathNodes(ruledef.Field, expression)
+	if err != nil {
+		return nil, false
+	}
+	var fields []string
+	for i := len(nodes) - 1; i >= 0; i-- {
+		f, ok := nodes[i].(*jsonpath.FieldNode)
+		if !ok || f.Value == "" {
+			break
+		}
+		fields = append([]string{escapeKey(f.Value)}, fields...)
+	}
+	prefix := expression
+	for range fields {
+		trimmed, ok := trimField(prefix)
+		if !ok {
+			return nil, false
+		}
+		prefix = trimmed
+	}
+	parents := []reflect.Value{reflect.ValueOf(data)}
+	if len(fields) < len(nodes) {
+		prefixNodes, err := jsonPathNodes(ruledef.Field, prefix)
+		if err != nil || len(prefixNodes) != len(nodes)-len(fields) {
+			return nil, false
+		}
+		if _, recursive := prefixNodes[len(prefixNodes)-1].(*jsonpath.RecursiveNode); recursive {
+			return nil, false
+		}
+		if parents, err = findJSONPath(ruledef.Field, prefix, data); err != nil {
+			return nil, false
+		}
+	}
+	paths := map[uintptr]string{}
+	index(paths, "", reflect.ValueOf(data))
+	var es []element
+	for _, p := range parents {
+		if p.Kind() == reflect.Interface {
+			p = p.Elem()
+		}
+		if p.Kind() == reflect.Slice && p.Len() == 0 {
+			continue
+		}
+		if p.Kind() != reflect.Map && p.Kind() != reflect.Slice {
+			return nil, false
+		}
+		parent, ok := paths[p.Pointer()]
+		if !ok {
+			return nil, false
+		}
+		path := strings.Join(append([]string{parent}, fields...), ".")
+		if parent == "" {
+			path = strings.Join(fields, ".")
+		}
+		if path == "" {
+			return nil, false
+		}
+		if r := gjson.Get(obj, path); r.Exists() {
+			es = append(es, flatten(path, r)...)
+		}
+	}
+	return es, true
+}
+
+//index records the gjson path of every object and non-empty array held by v
+func index(paths map[uintptr]string, path string, v reflect.Value) {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		paths[v.Pointer()] = path
+		for _, k := range v.MapKeys() {
+			index(paths, join(escapeKey(k.String())), v.MapIndex(k))
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return
+		}
+		paths[v.Pointer()] = path
+		for i := 0; i < v.Len(); i++ {
+			index(paths, join(strconv.Itoa(i)), v.Index(i))
+		}
+	}
+}
+
+//escapeKey escapes a key for a gjson path like gjson does for the paths of its results
+func escapeKey(key string) string {
+	var sb strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		safe := c <= ' ' || c > '~' || c == '_' || c == '-' || c == ':' ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !safe {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+//trimField removes the last field of a JSONPath expression like {.spec.containers[*].image}, it returns false when
+//the expression doesn't end with a field written with a dot
+func trimField(expression string) (string, bool) {
+	if !strings.HasPrefix(expression, "{") || !strings.HasSuffix(expression, "}") {
+		return "", false
+	}
+	for i := len(expression) - 2; i > 0; i-- {
+		switch expression[i] {
+		case '.':
+			if expression[i-1] == '\\' {
+				continue
+			}
+			return expression[:i] + "}", true
+		case ']', '[', ')', '(', '\'', '"', '{', '}', '@', '$':
+			return "", false
+		}
+	}
+	return "", false
+}
+
+//valuesOf returns the values of elements
+func valuesOf(es []element) []gjson.Result {
+	values := make([]gjson.Result, 0, len(es))
+	for _, e := range es {
+		values = append(values, e.value)
+	}
+	return values
+}
+
+//match returns the values a gjson path points to in obj with their paths
+func match(obj, path string) []element {
+	result := gjson.Get(obj, path)
+	if !result.Exists() {
+		return nil
+	}
+	//Results of a # query hold the path of each entry, except for nested queries
+	if paths, entries := result.Paths(obj), result.Array(); len(paths) > 0 && len(paths) == len(entries) && paths[0] != "" {
+		var es []element
+		for i, entry := range entries {
+			es = append(es, flatten(paths[i], entry)...)
+		}
+		return es
+	}
+	if p := result.Path(obj); p != "" {
+		return flatten(p, result)
+	}
+	//gjson doesn't keep the paths of nested # queries, the first # is expanded into each index of its array
+	if i := bareHash(path); i >= 0 {
+		prefix, rest := path[:i], path[i+1:]
+		var es []element
+		for j := range gjson.Get(obj, strings.TrimSuffix(prefix, ".")).Array() {
+			es = append(es, match(obj, fmt.Sprintf("%s%d%s", prefix, j, rest))...)
+		}
+		return es
+	}
+	return flatten("", result)
+}
+
+//flatten returns a value and its path, arrays hold one value per element like in objects
+func flatten(path string, result gjson.Result) []element {
+	at := func(i int) string {
+		if path == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s.%d", path, i)
+	}
+	if !result.IsArray() {
+		return []element{{path: path, value: result}}
+	}
+	var es []element
+	for i, value := range result.Array() {
+		if !value.IsArray() {
+			es = append(es, element{path: at(i), value: value})
+			continue
+		}
+		for j, v := range value.Array() {
+			p := at(i)
+			if p != "" {
+				p = fmt.Sprintf("%s.%d", p, j)
+			}
+			es = append(es, element{path: p, value: v})
+		}
+	}
+	return es
+}
+
+//bareHash returns the index of the first # component of a gjson path followed by more components, -1 if there is none
+func bareHash(path string) int {
+	depth := 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '#':
+			if depth == 0 && (i == 0 || path[i-1] == '.') && i+1 < len(path) && path[i+1] == '.' {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+//elementName returns the name of the innermost array element holding path in obj, like the name of a container
+func elementName(obj, path string) string {
+	parts := strings.Split(path, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if _, err := strconv.Atoi(parts[i]); err != nil {
+			continue
+		}
+		if name := gjson.Get(obj, strings.Join(parts[:i+1], ".")+".name"); name.Type == gjson.String {
+			return name.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,286 @@
+package rules
+
+import (
+	"testing"
+
+	livr "github.com/k33nice/go-livr"
+	"gotest.tools/assert"
+)
+
+var meshPod = `{
+    "kind": "Pod",
+    "spec": {
+        "containers": [
+            {"name": "app", "image": "vivareal/app:1.0"},
+            {"name": "istio-proxy", "image": "istio/proxyv2:latest"}
+        ]
+    }
+}`
+
+func imageRule(field, syntax string) RuleDefinition {
+	return RuleDefinition{
+		Field:       field,
+		FieldSyntax: syntax,
+		LivrRule: RuleObject{
+			Description: "Images must come from the vivareal organization",
+			RuleObj:     livr.Dictionary{"image": livr.Dictionary{"like": "^vivareal/"}},
+		},
+	}
+}
+
+func TestSelectFieldSyntaxes(t *testing.T) {
+	fields := []RuleDefinition{
+		{Field: `spec.containers.#(name!="istio-proxy")#.image`},
+		{Field: `{.spec.containers[?(@.name!="istio-proxy")].image}`, FieldSyntax: FieldSyntaxJSONPath},
+		{Field: `spec.containers[?(@.name!="istio-proxy")].image`, FieldSyntax: FieldSyntaxJSONPath},
+	}
+	for _, ruledef := range fields {
+		result, err := ruledef.selectField(meshPod)
+		assert.NilError(t, err)
+		values := objects(result)
+		assert.Equal(t, len(values), 1, ruledef.Field)
+		assert.Equal(t, values[0].String(), "vivareal/app:1.0", ruledef.Field)
+	}
+
+	ruledef := RuleDefinition{Field: "{.spec.containers[*].name}", FieldSyntax: FieldSyntaxJSONPath}
+	result, err := ruledef.selectField(meshPod)
+	assert.NilError(t, err)
+	assert.Equal(t, len(objects(result)), 2)
+
+	ruledef = RuleDefinition{Field: "{.spec.volumes[*].name}", FieldSyntax: FieldSyntaxJSONPath}
+	result, err = ruledef.selectField(meshPod)
+	assert.NilError(t, err)
+	assert.Assert(t, !result.Exists())
+}
+
+func TestGetViolationsJSONPath(t *testing.T) {
+	ruledef := imageRule("{.spec.containers[*].image}", FieldSyntaxJSONPath)
+	violations := ruledef.GetViolations(meshPod)
+	assert.Equal(t, len(violations), 1)
+	assert.DeepEqual(t, violations[0].Value(), "istio/proxyv2:latest")
+	assert.Equal(t, violations[0].Path, "spec.containers[1].image")
+	assert.Equal(t, violations[0].Element, "istio-proxy")
+
+	ruledef = imageRule(`{.spec.containers[?(@.name!="istio-proxy")].image}`, FieldSyntaxJSONPath)
+	assert.Equal(t, len(ruledef.GetViolations(meshPod)), 0)
+
+	ruledef = imageRule(`{.spec.containers[?(@.name!="app")].image}`, FieldSyntaxJSONPath)
+	violations = ruledef.GetViolations(meshPod)
+	assert.Equal(t, len(violations), 1)
+	assert.Equal(t, violations[0].Path, "spec.containers[1].image")
+	assert.Equal(t, violations[0].Element, "istio-proxy")
+}
+
+func TestCompileFieldSyntax(t *testing.T) {
+	assert.NilError(t, (&RuleDefinition{Field: "spec.containers.#.image"}).Compile())
+	assert.NilError(t, (&RuleDefinition{Field: "{.spec.containers[*].image}", FieldSyntax: FieldSyntaxJSONPath}).Compile())
+	assert.ErrorContains(t, (&RuleDefinition{Field: "{.spec.containers[*.image}", FieldSyntax: FieldSyntaxJSONPath}).Compile(), "")
+	assert.Error(t, (&RuleDefinition{Field: "spec", FieldSyntax: "jmespath"}).Compile(), `unknown field_syntax "jmespath", use "gjson" or "jsonpath"`)
+}
+
+func TestPath(t *testing.T) {
+	paths := map[string]string{
+		"{.spec.template.spec.containers[*].image}":                        "spec.template.spec.containers.#.image",
+		`spec.containers[?(@.name!="istio-proxy")].ports[0].containerPort`: "spec.containers.#.ports.#.containerPort",
+		`{.metadata.annotations.example\.com/owner}`:                       `metadata.annotations.example\.com/owner`,
+		"{..image}": "*.image",
+	}
+	for field, expected := range paths {
+		path, err := (&RuleDefinition{Field: field, FieldSyntax: FieldSyntaxJSONPath}).Path()
+		assert.NilError(t, err, field)
+		assert.Equal(t, path, expected, field)
+	}
+}
+
+var resourcesPod = `{
+    "kind": "Pod",
+    "spec": {
+        "containers": [
+            {"name": "app", "resources": {"requests": {"cpu": "500m"}, "limits": {"cpu": "500m"}}},
+            {"name": "worker", "resources": {"requests": {"cpu": "1"}, "limits": {"cpu": "2"}}}
+        ]
+    }
+}`
+
+func TestGetViolationsBind(t *testing.T) {
+	ruledef := RuleDefinition{
+		Field: "spec.containers",
+		Bind:  map[string]string{"name": "name", "requests": "resources.requests.cpu", "limits": "resources.limits.cpu"},
+		LivrRule: RuleObject{
+			Description: "Guaranteed QoS",
+			RuleObj:     livr.Dictionary{"requests": []interface{}{"required", livr.Dictionary{"equal_to_field": "limits"}}},
+		},
+	}
+	violations := ruledef.GetViolations(resourcesPod)
+	assert.Equal(t, len(violations), 1)
+	assert.DeepEqual(t, violations[0].Object, map[string]interface{}{"name": "worker", "requests": "1", "limits": "2"})
+}
+
+func TestGetViolationsBindMixedUnits(t *testing.T) {
+	pod := `{"kind": "Pod", "spec": {"containers": [
+		{"name": "app", "resources": {"requests": {"memory": "900Mi"}, "limits": {"memory": "1Gi"}}},
+		{"name": "worker", "resources": {"requests": {"memory": "1Gi"}, "limits": {"memory": "900Mi"}}}
+	]}}`
+	ruledef := RuleDefinition{
+		Field: "spec.containers",
+		Bind:  map[string]string{"name": "name", "requests": "resources.requests.memory", "limits": "resources.limits.memory"},
+		LivrRule: RuleObject{
+			Description: "Memory requests can't exceed the limits",
+			RuleObj:     livr.Dictionary{"requests": livr.Dictionary{"less_or_equal_to_field": "limits"}},
+		},
+	}
+	violations := ruledef.GetViolations(pod)
+	assert.Equal(t, len(violations), 1)
+	assert.DeepEqual(t, violations[0].Object, map[string]interface{}{"name": "worker", "requests": "1Gi", "limits": "900Mi"})
+
+	ruledef.LivrRule.RuleObj = livr.Dictionary{"limits": livr.Dictionary{"greater_than_field": "requests"}}
+	violations = ruledef.GetViolations(pod)
+	assert.Equal(t, len(violations), 1)
+	assert.DeepEqual(t, violations[0].Object, map[string]interface{}{"name": "worker", "requests": "1Gi", "limits": "900Mi"})
+}
+
+func TestGetViolationsQuantityAndDuration(t *testing.T) {
+	limits := RuleDefinition{
+		Field: "spec.containers.#.resources.limits.memory",
+		LivrRule: RuleObject{
+			Description: "Memory limits can't exceed 1Gi",
+			RuleObj:     livr.Dictionary{"memory": livr.Dictionary{"quantity_max": "1Gi"}},
+		},
+	}
+	pod := `{"kind": "Pod", "spec": {"containers": [
+		{"name": "app", "resources": {"limits": {"memory": "1024Mi"}}},
+		{"name": "worker", "resources": {"limits": {"memory": "2Gi"}}}
+	]}}`
+	violations := limits.GetViolations(pod)
+	assert.Equal(t, len(violations), 1)
+	assert.DeepEqual(t, violations[0].Object, map[string]interface{}{"memory": "2Gi"})
+
+	obj := `{"kind": "Certificate", "spec": {"duration": "2160h", "renewBefore": "30m"}}`
+	renewal := RuleDefinition{
+		Field: "spec.renewBefore",
+		LivrRule: RuleObject{
+			Description: "Certificates are renewed between 1h and 720h before they expire",
+			RuleObj:     livr.Dictionary{"renewBefore": livr.Dictionary{"duration_between": []interface{}{"1h", "720h"}}},
+		},
+	}
+	violations = renewal.GetViolations(obj)
+	assert.Equal(t, len(violations), 1)
+	assert.DeepEqual(t, violations[0].Object, map[string]interface{}{"renewBefore": "30m"})
+
+	renewal.Field = "spec.duration"
+	renewal.LivrRule.RuleObj = livr.Dictionary{"duration": livr.Dictionary{"duration_between": []interface{}{"1h", "2160h"}}}
+	assert.Equal(t, len(renewal.GetViolations(obj)), 0)
+}
+
+func TestGetViolationsImages(t *testing.T) {
+	ruledef := RuleDefinition{
+		Field: "spec.containers.#.image",
+		LivrRule: RuleObject{
+			Description: "Images come from the company registry with a released version",
+			RuleObj: livr.Dictionary{"image": []interface{}{
+				livr.Dictionary{"image_registry_in": []interface{}{"docker.io/vivareal", "docker.io/istio"}},
+				livr.Dictionary{"image_tag_not": "latest"},
+				livr.Dictionary{"image_semver": ">= 1.0"},
+			}},
+		},
+	}
+	violations := ruledef.GetViolations(meshPod)
+	assert.Equal(t, len(violations), 1)
+	assert.DeepEqual(t, violations[0].Object, map[string]interface{}{"image": "istio/proxyv2:latest"})
+
+	pod := `{"kind": "Pod", "spec": {"containers": [
+		{"name": "app", "image": "vivareal/app:0.9.1"},
+		{"name": "cache", "image": "redis:6.0"}
+	]}}`
+	violations = ruledef.GetViolations(pod)
+	assert.Equal(t, len(violations), 2)
+	assert.DeepEqual(t, violations[0].Object, map[string]interface{}{"image": "vivareal/app:0.9.1"})
+	assert.DeepEqual(t, violations[1].Object, map[string]interface{}{"image": "redis:6.0"})
+}
+
+func TestElements(t *testing.T) {
+	obj := `{"spec": {"containers": [
+        {"name": "app", "image": "app:1.0", "ports": [{"name": "http", "containerPort": 80}, {"containerPort": 81}]},
+        {"name": "istio-proxy", "image": "istio:latest"},
+        {"name": "worker", "image": "app:1.0", "ports": [{"name": "metrics", "containerPort": 82}]}
+    ]}}`
+	paths := map[string][][]string{
+		"spec.containers.#.image": {
+			{"spec.containers.0.image", "app:1.0", "app"}, {"spec.containers.1.image", "istio:latest", "istio-proxy"}, {"spec.containers.2.image", "app:1.0", "worker"},
+		},
+		`spec.containers.#(name!="istio-proxy")#.image`: {
+			{"spec.containers.0.image", "app:1.0", "app"}, {"spec.containers.2.image", "app:1.0", "worker"},
+		},
+		`spec.containers.#(name=="worker").image`: {{"spec.containers.2.image", "app:1.0", "worker"}},
+		"spec.containers.#.ports.#.containerPort": {
+			{"spec.containers.0.ports.0.containerPort", "80", "http"}, {"spec.containers.0.ports.1.containerPort", "81", "app"}, {"spec.containers.2.ports.0.containerPort", "82", "metrics"},
+		},
+		"spec.containers.1.name": {{"spec.containers.1.name", "istio-proxy", "istio-proxy"}},
+		"spec.missing":           nil,
+	}
+	for field, expected := range paths {
+		ruledef := RuleDefinition{Field: field}
+		var got [][]string
+		_, es, err := ruledef.elements(obj)
+		assert.NilError(t, err)
+		for _, e := range es {
+			got = append(got, []string{e.path, e.value.String(), elementName(obj, e.path)})
+		}
+		assert.DeepEqual(t, got, expected)
+	}
+
+	ruledef := RuleDefinition{Field: "spec.containers", FieldSyntax: FieldSyntaxJSONPath}
+	_, es, err := ruledef.elements(obj)
+	assert.NilError(t, err)
+	assert.Equal(t, len(es), 3)
+	assert.Equal(t, es[2].path, "spec.containers.2")
+	assert.Equal(t, elementName(obj, es[2].path), "worker")
+}
+
+func TestElementsSyntaxesAgree(t *testing.T) {
+	pod := `{"metadata": {"labels": {"app.kubernetes.io/name": "app"}}, "spec": {"containers": [
+		{"name": "app", "image": "app:1.0", "ports": [{"containerPort": 80}, {"containerPort": 81}]},
+		{"name": "istio-proxy", "image": "istio/proxyv2:latest"},
+		{"name": "worker", "image": "worker:1.0", "ports": [{"containerPort": 82}]}
+	]}}`
+	fields := [][2]string{
+		{"spec.containers.#.image", "{.spec.containers[*].image}"},
+		{"spec.containers.#.ports.#.containerPort", "{.spec.containers[*].ports[*].containerPort}"},
+		{"spec.volumes.#.name", "{.spec.volumes[*].name}"},
+		{"metadata.missing", "{.metadata.missing}"},
+		{`metadata.labels.app\.kubernetes\.io/name`, `{.metadata.labels.app\.kubernetes\.io/name}`},
+		{"spec.containers.#.ports", "{.spec.containers[*].ports}"},
+		{"spec.containers", "{.spec.containers}"},
+	}
+	for _, f := range fields {
+		_, gjsonElements, err := (&RuleDefinition{Field: f[0]}).elements(pod)
+		assert.NilError(t, err)
+		_, jsonPathElements, err := (&RuleDefinition{Field: f[1], FieldSyntax: FieldSyntaxJSONPath}).elements(pod)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, texts(jsonPathElements), texts(gjsonElements))
+		assert.DeepEqual(t, paths(jsonPathElements), paths(gjsonElements))
+		assert.Equal(t, len(GetJSONObjectByPath(pod, f[0])), len(gjsonElements), f[0])
+	}
+
+	ruledef := imageRule("{.spec.volumes[*].image}", FieldSyntaxJSONPath)
+	ruledef.FieldIsOptional = true
+	assert.Equal(t, len(ruledef.GetViolations(pod)), 0)
+}
+
+//paths returns the paths of elements
+func paths(es []element) []string {
+	var ps []string
+	for _, e := range es {
+		ps = append(ps, e.path)
+	}
+	return ps
+}
+
+//texts returns the JSON of the values of elements
+func texts(es []element) []string {
+	var ts []string
+	for _, v := range valuesOf(es) {
+		ts = append(ts, v.String())
+	}
+	return ts
+}
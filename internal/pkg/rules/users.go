@@ -0,0 +1,41 @@
+package rules
+
+import (
+	"strings"
+
+	"github.com/grupozap/aegir/internal/pkg/utils"
+)
+
+//serviceAccountPrefix starts the username of service accounts, like system:serviceaccount:kube-system:replicaset-controller
+const serviceAccountPrefix = "system:serviceaccount:"
+
+//AppliesTo reports whether the rule is evaluated for the requests of a user and its groups.
+//A rule with match_users only applies to the matching users, exclude_users, exclude_groups and exclude_service_accounts bypass it.
+func (rule *Rule) AppliesTo(user string, groups []string) bool {
+	if len(rule.MatchUsers) > 0 && !matchAny(rule.MatchUsers, user) {
+		return false
+	}
+	if matchAny(rule.ExcludeUsers, user) {
+		return false
+	}
+	for _, group := range groups {
+		if matchAny(rule.ExcludeGroups, group) {
+			return false
+		}
+	}
+	//Service accounts are matched as namespace:name, or by their whole username
+	if sa := strings.TrimPrefix(user, serviceAccountPrefix); sa != user && (matchAny(rule.ExcludeServiceAccounts, sa) || matchAny(rule.ExcludeServiceAccounts, user)) {
+		return false
+	}
+	return true
+}
+
+//matchAny reports whether s matches any of the glob patterns
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if utils.MatchGlob(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
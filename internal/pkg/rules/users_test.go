@@ -0,0 +1,37 @@
+package rules
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestAppliesTo(t *testing.T) {
+	rule := &Rule{
+		ExcludeUsers:           []string{"break-glass-*"},
+		ExcludeGroups:          []string{"system:masters"},
+		ExcludeServiceAccounts: []string{"kube-system:*", "system:serviceaccount:argocd:argocd-application-controller"},
+	}
+	requests := []struct {
+		user    string
+		groups  []string
+		applies bool
+	}{
+		{"jane@example.com", []string{"developers", "system:authenticated"}, true},
+		{"break-glass-jane", nil, false},
+		{"admin", []string{"system:masters"}, false},
+		{"system:serviceaccount:kube-system:replicaset-controller", []string{"system:serviceaccounts"}, false},
+		{"system:serviceaccount:argocd:argocd-application-controller", nil, false},
+		{"system:serviceaccount:argocd:argocd-server", nil, true},
+		{"kube-system:replicaset-controller", nil, true},
+	}
+	for _, r := range requests {
+		assert.Equal(t, rule.AppliesTo(r.user, r.groups), r.applies, r.user)
+	}
+
+	rule = &Rule{MatchUsers: []string{"*@contractors.example.com"}, ExcludeUsers: []string{"lead@contractors.example.com"}}
+	assert.Assert(t, rule.AppliesTo("joe@contractors.example.com", nil))
+	assert.Assert(t, !rule.AppliesTo("jane@example.com", nil))
+	assert.Assert(t, !rule.AppliesTo("lead@contractors.example.com", nil))
+	assert.Assert(t, (&Rule{}).AppliesTo("", nil))
+}
@@ -0,0 +1,183 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	y2j "github.com/ghodss/yaml"
+	"github.com/grupozap/aegir/internal/pkg/kinds"
+)
+
+//openAPISchema holds the subset of an OpenAPI schema object needed to resolve field paths
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref"`
+	Type                 string                    `json:"type"`
+	Properties           map[string]*openAPISchema `json:"properties"`
+	Items                *openAPISchema            `json:"items"`
+	AdditionalProperties json.RawMessage           `json:"additionalProperties"`
+	AllOf                []*openAPISchema          `json:"allOf"`
+	PreserveUnknown      bool                      `json:"x-kubernetes-preserve-unknown-fields"`
+	IntOrString          bool                      `json:"x-kubernetes-int-or-string"`
+	GroupVersionKinds    []struct {
+		Group   string `json:"group"`
+		Version string `json:"version"`
+		Kind    string `json:"kind"`
+	} `json:"x-kubernetes-group-version-kind"`
+}
+
+type openAPIDocument struct {
+	Swagger     string                    `json:"swagger"`
+	OpenAPI     string                    `json:"openapi"`
+	Definitions map[string]*openAPISchema `json:"definitions"`
+	Components  struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+//LoadOpenAPI reads an OpenAPI v2 (swagger.json) or v3 document, in JSON or YAML,
+//and returns the schemas of the kinds it defines, keyed by kind name.
+//Eg: kubectl get --raw /openapi/v2 or kubectl get --raw /openapi/v3/apis/apps/v1
+func LoadOpenAPI(path string) (map[string][]*Node, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	j, err := y2j.YAMLToJSON(content)
+	if err != nil {
+		return nil, err
+	}
+	var doc openAPIDocument
+	if err := json.Unmarshal(j, &doc); err != nil {
+		return nil, err
+	}
+
+	definitions, prefix := doc.Definitions, "#/definitions/"
+	if doc.OpenAPI != "" {
+		definitions, prefix = doc.Components.Schemas, "#/components/schemas/"
+	} else if doc.Swagger == "" {
+		return nil, fmt.Errorf("%s is not an OpenAPI document", path)
+	}
+
+	c := &converter{definitions: definitions, prefix: prefix, nodes: map[string]*Node{}}
+	kinds := map[string][]*Node{}
+	for name, s := range definitions {
+		for _, gvk := range s.GroupVersionKinds {
+			node, err := c.ref(prefix + name)
+			if err != nil {
+				return nil, err
+			}
+			kinds[gvk.Kind] = append(kinds[gvk.Kind], node)
+		}
+	}
+	return kinds, nil
+}
+
+type converter struct {
+	definitions map[string]*openAPISchema
+	prefix      string
+	nodes       map[string]*Node
+}
+
+func (c *converter) ref(ref string) (*Node, error) {
+	if n, ok := c.nodes[ref]; ok {
+		return n, nil
+	}
+	s, ok := c.definitions[strings.TrimPrefix(ref, c.prefix)]
+	if !ok {
+		return nil, fmt.Errorf("could not resolve $ref %q", ref)
+	}
+	//Register the node before converting it, so recursive schemas point to themselves
+	n := &Node{}
+	c.nodes[ref] = n
+	converted, err := c.convert(s)
+	if err != nil {
+		return nil, err
+	}
+	*n = *converted
+	return n, nil
+}
+
+func (c *converter) convert(s *openAPISchema) (*Node, error) {
+	if s.Ref != "" {
+		return c.ref(s.Ref)
+	}
+	if len(s.AllOf) == 1 && s.Type == "" && len(s.Properties) == 0 {
+		return c.convert(s.AllOf[0])
+	}
+	if s.PreserveUnknown {
+		return &Node{Any: true}, nil
+	}
+	if s.IntOrString {
+		return &Node{}, nil
+	}
+
+	switch {
+	case s.Type == "array":
+		if s.Items == nil {
+			return &Node{Items: &Node{Any: true}}, nil
+		}
+		items, err := c.convert(s.Items)
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Items: items}, nil
+	case len(s.Properties) > 0:
+		n := &Node{Fields: map[string]*Node{}}
+		for name, p := range s.Properties {
+			field, err := c.convert(p)
+			if err != nil {
+				return nil, err
+			}
+			n.Fields[name] = field
+		}
+		return n, nil
+	case len(s.AdditionalProperties) > 0:
+		var values openAPISchema
+		if err := json.Unmarshal(s.AdditionalProperties, &values); err != nil {
+			//additionalProperties: true
+			return &Node{Any: true}, nil
+		}
+		v, err := c.convert(&values)
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Values: v}, nil
+	case s.Type == "object" || s.Type == "":
+		//Objects without properties, like RawExtension, accept anything
+		return &Node{Any: true}, nil
+	}
+	return &Node{}, nil
+}
+
+var (
+	mu         sync.RWMutex
+	registered = map[string][]*Node{}
+)
+
+//Register makes the schemas of a kind known, replacing its built-in schema.
+//A kind served in several versions can have one schema per version.
+func Register(kinds map[string][]*Node) {
+	mu.Lock()
+	defer mu.Unlock()
+	for kind, nodes := range kinds {
+		registered[kind] = nodes
+	}
+}
+
+//ForKind returns the schemas of a kind: the registered ones, or the one built
+//from its Go type. It returns nil when the shape of the kind is unknown.
+func ForKind(kind string) []*Node {
+	mu.RLock()
+	nodes, ok := registered[kind]
+	mu.RUnlock()
+	if ok {
+		return nodes
+	}
+	if k, ok := kinds.Lookup(kind); ok && k.Object != nil {
+		return []*Node{FromObject(k.Object)}
+	}
+	return nil
+}
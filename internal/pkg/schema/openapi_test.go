@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+const certificateV2 = `{
+  "swagger": "2.0",
+  "definitions": {
+    "io.cert-manager.v1.Certificate": {
+      "type": "object",
+      "x-kubernetes-group-version-kind": [{"group": "cert-manager.io", "version": "v1", "kind": "Certificate"}],
+      "properties": {
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "spec": {
+          "type": "object",
+          "properties": {
+            "dnsNames": {"type": "array", "items": {"type": "string"}},
+            "secretName": {"type": "string"},
+            "duration": {"type": "string"},
+            "keystores": {"type": "object", "x-kubernetes-preserve-unknown-fields": true}
+          }
+        }
+      }
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    }
+  }
+}`
+
+const certificateV3 = `
+openapi: 3.0.0
+components:
+  schemas:
+    io.cert-manager.v1.Certificate:
+      type: object
+      x-kubernetes-group-version-kind:
+      - group: cert-manager.io
+        version: v1
+        kind: Certificate
+      properties:
+        metadata:
+          allOf:
+          - $ref: '#/components/schemas/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta'
+        spec:
+          type: object
+          properties:
+            secretName:
+              type: string
+    io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta:
+      type: object
+      properties:
+        labels:
+          type: object
+          additionalProperties:
+            type: string
+`
+
+func writeFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "aegir-schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "openapi")
+	ioutil.WriteFile(path, []byte(content), 0600)
+	return path
+}
+
+func TestLoadOpenAPIV2(t *testing.T) {
+	kinds, err := LoadOpenAPI(writeFile(t, certificateV2))
+	assert.NilError(t, err)
+	assert.Equal(t, len(kinds["Certificate"]), 1)
+	certificate := kinds["Certificate"][0]
+	for _, path := range []string{"metadata.labels.app", "spec.dnsNames.#", "spec.secretName", "spec.keystores.jks.create"} {
+		if _, err := certificate.Resolve(path); err != nil {
+			t.Errorf("expected %s to resolve but got '%v'", path, err)
+		}
+	}
+	_, err = certificate.Resolve("spec.secretname")
+	assert.Error(t, err, `field "secretname" does not exist in "spec"`)
+	suggestion, _ := err.(*PathError).Suggestion()
+	assert.Equal(t, suggestion, "spec.secretName")
+}
+
+func TestLoadOpenAPIV3(t *testing.T) {
+	kinds, err := LoadOpenAPI(writeFile(t, certificateV3))
+	assert.NilError(t, err)
+	certificate := kinds["Certificate"][0]
+	_, err = certificate.Resolve("metadata.labels.app")
+	assert.NilError(t, err)
+	_, err = certificate.Resolve("spec.issuerRef")
+	assert.Error(t, err, `field "issuerRef" does not exist in "spec"`)
+}
+
+func TestLoadOpenAPIRejectsOtherDocuments(t *testing.T) {
+	_, err := LoadOpenAPI(writeFile(t, `{"kind": "APIResourceList"}`))
+	assert.ErrorContains(t, err, "is not an OpenAPI document")
+}
+
+func TestForKind(t *testing.T) {
+	assert.Equal(t, len(ForKind("Deployment")), 1)
+	assert.Assert(t, ForKind("Certificate") == nil)
+
+	kinds, err := LoadOpenAPI(writeFile(t, certificateV2))
+	assert.NilError(t, err)
+	Register(kinds)
+	assert.Equal(t, len(ForKind("Certificate")), 1)
+}
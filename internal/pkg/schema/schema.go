@@ -0,0 +1,216 @@
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/grupozap/aegir/internal/pkg/utils"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+//Node describes the shape of a value inside a Kubernetes object
+type Node struct {
+	//Fields holds the properties of an object
+	Fields map[string]*Node
+	//Items describes the elements of an array
+	Items *Node
+	//Values describes the values of a map with arbitrary keys, like labels or resources.requests
+	Values *Node
+	//Any is set when the shape of the value is unknown, every path under it is accepted
+	Any bool
+}
+
+//IsLeaf reports whether the node is a scalar value
+func (n *Node) IsLeaf() bool {
+	return n.Fields == nil && n.Items == nil && n.Values == nil && !n.Any
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	rawExtension  = reflect.TypeOf(runtime.RawExtension{})
+)
+
+//FromObject builds the schema of a Kubernetes API Go type, following its json tags
+func FromObject(obj interface{}) *Node {
+	return fromType(reflect.TypeOf(obj), map[reflect.Type]*Node{})
+}
+
+func fromType(t reflect.Type, seen map[reflect.Type]*Node) *Node {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if n, ok := seen[t]; ok {
+		return n
+	}
+	if t == rawExtension {
+		return &Node{Any: true}
+	}
+	//Types with custom serialization, like Quantity, Time or IntOrString, are scalars
+	if t.Implements(jsonMarshaler) || reflect.PtrTo(t).Implements(jsonMarshaler) ||
+		t.Implements(textMarshaler) || reflect.PtrTo(t).Implements(textMarshaler) {
+		return &Node{}
+	}
+
+	n := &Node{}
+	seen[t] = n
+	switch t.Kind() {
+	case reflect.Struct:
+		n.Fields = map[string]*Node{}
+		addFields(n, t, seen)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			//[]byte is serialized as a base64 string
+			return n
+		}
+		n.Items = fromType(t.Elem(), seen)
+	case reflect.Map:
+		n.Values = fromType(t.Elem(), seen)
+	case reflect.Interface:
+		n.Any = true
+	}
+	return n
+}
+
+func addFields(n *Node, t reflect.Type, seen map[reflect.Type]*Node) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && (name == "" || strings.Contains(tag, ",inline")) {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(n, ft, seen)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		n.Fields[name] = fromType(f.Type, seen)
+	}
+}
+
+//SplitPath splits a gjson path into its components, honoring escaped dots
+func SplitPath(path string) []string {
+	var parts []string
+	sb := strings.Builder{}
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '\\' && i+1 < len(path):
+			i++
+			sb.WriteByte(path[i])
+		case c == '.':
+			parts = append(parts, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return append(parts, sb.String())
+}
+
+func isIndex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//dynamic reports whether a path component can't be checked statically, like wildcards, queries and modifiers
+func dynamic(s string) bool {
+	return strings.ContainsAny(s, "*?|") || strings.HasPrefix(s, "#(") || strings.HasPrefix(s, "@")
+}
+
+//query reports whether a path component is a whole gjson query over an array, like #(name!="istio-proxy")#
+func query(s string) bool {
+	return strings.HasPrefix(s, "#(") && (strings.HasSuffix(s, ")") || strings.HasSuffix(s, ")#"))
+}
+
+//PathError describes the first component of a path that doesn't exist in a schema
+type PathError struct {
+	Path string
+	//Prefix is the part of the path that resolved
+	Prefix string
+	//Component is the first component that doesn't exist
+	Component string
+	//Node is where Component was looked up
+	Node *Node
+	//depth is the index of Component in the path
+	depth int
+}
+
+func (e *PathError) Error() string {
+	if e.Prefix == "" {
+		return fmt.Sprintf("field %q does not exist", e.Component)
+	}
+	return fmt.Sprintf("field %q does not exist in %q", e.Component, e.Prefix)
+}
+
+//Suggestion returns the path with the missing component replaced by the closest existing field
+func (e *PathError) Suggestion() (string, bool) {
+	if e.Node == nil || len(e.Node.Fields) == 0 {
+		return "", false
+	}
+	candidates := make([]string, 0, len(e.Node.Fields))
+	for name := range e.Node.Fields {
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+	closest, ok := utils.Closest(e.Component, candidates)
+	if !ok {
+		return "", false
+	}
+	parts := SplitPath(e.Path)
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(part, ".", "\\.")
+	}
+	parts[e.depth] = closest
+	return strings.Join(parts, "."), true
+}
+
+//Resolve walks a gjson path through the schema and returns the node it points to
+func (n *Node) Resolve(path string) (*Node, error) {
+	current := n
+	var prefix []string
+	for _, part := range SplitPath(path) {
+		if current.Any || (dynamic(part) && !query(part)) {
+			return &Node{Any: true}, nil
+		}
+		var next *Node
+		switch {
+		case current.Items != nil && (part == "#" || isIndex(part) || query(part)):
+			next = current.Items
+		case current.Values != nil:
+			next = current.Values
+		case current.Fields != nil:
+			next = current.Fields[part]
+		}
+		if next == nil {
+			return nil, &PathError{Path: path, Prefix: strings.Join(prefix, "."), Component: part, Node: current, depth: len(prefix)}
+		}
+		prefix = append(prefix, part)
+		current = next
+	}
+	return current, nil
+}
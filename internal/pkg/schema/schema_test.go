@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestSplitPath(t *testing.T) {
+	assert.DeepEqual(t, SplitPath("metadata.labels.app"), []string{"metadata", "labels", "app"})
+	assert.DeepEqual(t, SplitPath(`metadata.annotations.example\.com/owner`), []string{"metadata", "annotations", "example.com/owner"})
+}
+
+func TestResolve(t *testing.T) {
+	deployment := FromObject(&appsv1.Deployment{})
+	valid := []string{
+		"metadata.labels",
+		"metadata.labels.app",
+		`metadata.annotations.example\.com/owner`,
+		"spec.replicas",
+		"spec.template.spec.containers.#.name",
+		"spec.template.spec.containers.0.image",
+		"spec.template.spec.containers.#.ports.#.protocol",
+		"spec.template.spec.containers.#.resources.requests.cpu",
+		"spec.template.spec.securityContext.runAsUser",
+		"spec.template.spec.containers.#(name==\"app\").image",
+		"spec.template.spec.*.name",
+	}
+	for _, path := range valid {
+		if _, err := deployment.Resolve(path); err != nil {
+			t.Errorf("expected %s to resolve but got '%v'", path, err)
+		}
+	}
+
+	_, err := deployment.Resolve("spec.template.spec.container.#.name")
+	perr, ok := err.(*PathError)
+	if !ok {
+		t.Fatalf("expected a PathError but got '%v'", err)
+	}
+	assert.Equal(t, perr.Prefix, "spec.template.spec")
+	assert.Equal(t, perr.Component, "container")
+	suggestion, ok := perr.Suggestion()
+	assert.Assert(t, ok)
+	assert.Equal(t, suggestion, "spec.template.spec.containers.#.name")
+
+	invalid := []string{
+		"spec.containers.#.name",
+		"spec.replicas.value",
+		"spec.template.spec.containers.#.resources.requests.cpu.value",
+	}
+	for _, path := range invalid {
+		if _, err := deployment.Resolve(path); err == nil {
+			t.Errorf("expected %s not to resolve", path)
+		}
+	}
+}
@@ -2,21 +2,72 @@ package utils
 
 import (
 	"os"
+	"strconv"
 	"strings"
 )
 
 type Violation struct {
-	RuleName     string                 `json:"rule_name"`
-	Description  string                 `json:"description"`
-	JSONPath     string                 `json:"json_path"`
-	Object       map[string]interface{} `json:"object"`
-	Message      string                 `json:"error,omitempty"`
-	SlackChannel string                 `json:"slack_channel,omitempty"`
+	RuleName        string                 `json:"rule_name"`
+	Description     string                 `json:"description"`
+	JSONPath        string                 `json:"json_path"`
+	Path            string                 `json:"path,omitempty"`
+	Element         string                 `json:"element,omitempty"`
+	ContainerList   string                 `json:"container_list,omitempty"`
+	Container       string                 `json:"container,omitempty"`
+	Object          map[string]interface{} `json:"object"`
+	Actual          interface{}            `json:"value,omitempty"`
+	Errors          interface{}            `json:"errors,omitempty"`
+	Message         string                 `json:"error,omitempty"`
+	SlackChannel    string                 `json:"slack_channel,omitempty"`
+	EnforcementMode string                 `json:"enforcement_mode,omitempty"`
+	RemediationURL  string                 `json:"remediation_url,omitempty"`
+	Text            string                 `json:"text,omitempty"`
+	Notification    string                 `json:"-"`
+	EvaluationError bool                   `json:"evaluation_error,omitempty"`
 }
 
-//GetLastField returns the last word of a path delimited by '/'
+//Value returns the offending value held by the violation, if any
+func (v *Violation) Value() interface{} {
+	if v.Actual != nil {
+		return v.Actual
+	}
+	return v.Object[GetLastField(v.JSONPath)]
+}
+
+//FormatPath renders a dot separated path with its indexes between brackets, like spec.containers[2].name
+func FormatPath(path string) string {
+	sb := strings.Builder{}
+	for i, part := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			sb.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(part)
+	}
+	return sb.String()
+}
+
+//GetLastField returns the last word of a path delimited by '.',
+//ignoring queries, filters and indexes like #(name=="app") or [?(@.name=="app")]
 func GetLastField(field string) string {
-	s := strings.Split(strings.TrimRight(field, "/"), ".")
+	sb := strings.Builder{}
+	depth := 0
+	for _, c := range strings.Trim(field, "{}/ ") {
+		switch c {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		default:
+			if depth == 0 {
+				sb.WriteRune(c)
+			}
+		}
+	}
+	s := strings.Split(sb.String(), ".")
 	return s[len(s)-1]
 }
 
@@ -34,14 +85,97 @@ func Include(vs []string, t string) bool {
 	return index(vs, t) >= 0
 }
 
+//MatchGlob reports whether s matches pattern, where * matches any sequence of characters, including '/', and ? any character.
+//It is called for every rule and exemption of every request, so it matches in place instead of compiling the pattern.
+func MatchGlob(pattern, s string) bool {
+	p, t := []rune(pattern), []rune(s)
+	//star is the index in p of the last * seen, next the index in t it is retried from when a later character doesn't match
+	star, next := -1, 0
+	i, j := 0, 0
+	for j < len(t) {
+		switch {
+		case i < len(p) && p[i] == '*':
+			star, next = i, j
+			i++
+		case i < len(p) && (p[i] == '?' || p[i] == t[j]):
+			i++
+			j++
+		case star >= 0:
+			next++
+			i, j = star+1, next
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
+
 func GetEnvAsSlice(name string, sep string) ([]string, bool) {
 	valStr, ok := os.LookupEnv(name)
 	return strings.Split(valStr, sep), ok
 }
 
+//GetEnv returns the value of the environment variable name or fallback when it is not set
+func GetEnv(name, fallback string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return fallback
+}
+
 func FirstArg(args ...interface{}) interface{} {
 	if len(args) > 0 {
 		return args[0]
 	}
 	return nil
 }
+
+//levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+//Closest returns the candidate most similar to word, if any is close enough to be a typo of it
+func Closest(word string, candidates []string) (string, bool) {
+	best, bestDistance := "", -1
+	lower := strings.ToLower(word)
+	for _, c := range candidates {
+		d := levenshtein(lower, strings.ToLower(c))
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	if bestDistance < 0 || bestDistance > len(word)/3+1 {
+		return "", false
+	}
+	return best, true
+}
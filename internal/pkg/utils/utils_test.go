@@ -15,6 +15,75 @@ func TestGetLastField(t *testing.T) {
 	}
 }
 
+func TestGetLastFieldIgnoresQueries(t *testing.T) {
+	fields := map[string]string{
+		`spec.containers.#(name!="istio-proxy")#.image`:     "image",
+		`{.spec.containers[?(@.name!="a.b")].image}`:        "image",
+		"spec.template.spec.containers[*].resources.limits": "limits",
+	}
+	for field, expected := range fields {
+		if lastfield := GetLastField(field); lastfield != expected {
+			t.Errorf("expected '%s' but got '%s'", expected, lastfield)
+		}
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	paths := map[string]string{
+		"spec.template.spec.containers.2.name":    "spec.template.spec.containers[2].name",
+		"spec.containers.0.ports.1":               "spec.containers[0].ports[1]",
+		`metadata.annotations.example\.com/owner`: `metadata.annotations.example\.com/owner`,
+		"": "",
+	}
+	for path, expected := range paths {
+		if formatted := FormatPath(path); formatted != expected {
+			t.Errorf("expected '%s' but got '%s'", expected, formatted)
+		}
+	}
+}
+
+func TestViolationValue(t *testing.T) {
+	v := &Violation{JSONPath: "spec.containers.#.image", Object: map[string]interface{}{"image": "app:latest"}}
+	if v.Value() != "app:latest" {
+		t.Errorf("expected '%s' but got '%v'", "app:latest", v.Value())
+	}
+	v.Actual = "proxy:latest"
+	if v.Value() != "proxy:latest" {
+		t.Errorf("expected '%s' but got '%v'", "proxy:latest", v.Value())
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	matches := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"*", "anything", true},
+		{"pod-security/*", "pod-security/v1/baseline/privileged", true},
+		{"legacy-*", "legacy-api", true},
+		{"legacy-*", "api-legacy", false},
+		{"app-?", "app-1", true},
+		{"app-?", "app-10", false},
+		{"system:serviceaccount:kube-system:*", "system:serviceaccount:kube-system:replicaset-controller", true},
+		{"a.b", "axb", false},
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"a*b*c", "a-b-b-c", true},
+		{"a*b*c", "a-b-c-d", false},
+		{"*-api", "legacy-api-api", true},
+		{"??*", "a", false},
+		{"caf?", "café", true},
+		{"[a]", "a", false},
+		{"[a]", "[a]", true},
+	}
+	for _, m := range matches {
+		if MatchGlob(m.pattern, m.s) != m.match {
+			t.Errorf("expected MatchGlob(%q, %q) to be %v", m.pattern, m.s, m.match)
+		}
+	}
+}
+
 func TestIndex(t *testing.T) {
 	expected := 1
 	strgs := []string{"hello", "world", "computer"}
@@ -50,3 +119,16 @@ func TestGetEnvAsSlice(t *testing.T) {
 		t.Errorf("expected '%s' but got '%s'", expected, result)
 	}
 }
+
+func TestClosest(t *testing.T) {
+	candidates := []string{"Deployment", "DaemonSet", "StatefulSet", "Pod"}
+	if result, ok := Closest("Deploymnet", candidates); !ok || result != "Deployment" {
+		t.Errorf("expected '%s' but got '%s'", "Deployment", result)
+	}
+	if result, ok := Closest("pod", candidates); !ok || result != "Pod" {
+		t.Errorf("expected '%s' but got '%s'", "Pod", result)
+	}
+	if result, ok := Closest("Ingress", candidates); ok {
+		t.Errorf("expected no suggestion but got '%s'", result)
+	}
+}
@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/grupozap/aegir/internal/pkg/certs"
+	"github.com/grupozap/aegir/internal/pkg/rules"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	caCertKey = "ca.crt"
+	caKeyKey  = "ca.key"
+
+	certValidity = 365 * 24 * time.Hour
+	//renewBefore is how long before expiring a stored certificate is replaced
+	renewBefore = 30 * 24 * time.Hour
+)
+
+//Provisioner generates Aegir's serving certificate and registers the webhook through the Kubernetes API
+type Provisioner struct {
+	Client     kubernetes.Interface
+	SecretName string
+	Webhook    Options
+}
+
+//DNSNames returns the names the serving certificate must be valid for
+func (p *Provisioner) DNSNames() []string {
+	svc, ns := p.Webhook.ServiceName, p.Webhook.ServiceNamespace
+	return []string{
+		svc,
+		fmt.Sprintf("%s.%s", svc, ns),
+		fmt.Sprintf("%s.%s.svc", svc, ns),
+		fmt.Sprintf("%s.%s.svc.cluster.local", svc, ns),
+	}
+}
+
+//EnsureCertificate returns the serving key pair and CA stored in the Secret, storing a new serving key pair
+//when the Secret doesn't exist or its certificate is invalid or about to expire.
+//The stored CA signs the new key pair while it is valid, so the caBundle trusted by the other replicas doesn't change.
+//When another replica stores a key pair first, its key pair is returned.
+func (p *Provisioner) EnsureCertificate(ctx context.Context) (serving *certs.KeyPair, ca *certs.KeyPair, err error) {
+	secrets := p.Client.CoreV1().Secrets(p.Webhook.ServiceNamespace)
+	secret, err := secrets.Get(ctx, p.SecretName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		secret = nil
+	case err != nil:
+		return nil, nil, err
+	default:
+		if serving, ca, ok := p.stored(secret); ok {
+			return serving, ca, nil
+		}
+		ca = storedCA(secret)
+	}
+
+	if ca == nil {
+		if ca, err = certs.GenerateCA("Aegir Admission Controller Webhook CA", certValidity); err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Generated a new CA for secret %s/%s", p.Webhook.ServiceNamespace, p.SecretName)
+	}
+	if serving, err = ca.Sign(p.DNSNames(), certValidity); err != nil {
+		return nil, nil, err
+	}
+	data := map[string][]byte{
+		corev1.TLSCertKey:       serving.CertPEM,
+		corev1.TLSPrivateKeyKey: serving.KeyPEM,
+		caCertKey:               ca.CertPEM,
+		caKeyKey:                ca.KeyPEM,
+	}
+
+	if secret == nil {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: p.SecretName, Namespace: p.Webhook.ServiceNamespace},
+			Type:       corev1.SecretTypeTLS,
+			Data:       data,
+		}
+		created, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			//Another replica won the race, use what it stored
+			return p.EnsureCertificate(ctx)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Stored new TLS certificate in secret %s/%s", created.Namespace, created.Name)
+		return serving, ca, nil
+	}
+
+	secret.Data = data
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		//Another replica renewed it first, use what it stored
+		return p.EnsureCertificate(ctx)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Renewed TLS certificate stored in secret %s/%s", secret.Namespace, secret.Name)
+	return serving, ca, nil
+}
+
+//storedCA returns the CA held by secret when it can still sign certificates, nil otherwise
+func storedCA(secret *corev1.Secret) *certs.KeyPair {
+	ca, err := certs.ParseKeyPair(secret.Data[caCertKey], secret.Data[caKeyKey])
+	if err != nil || !ca.Certificate().IsCA || time.Now().Add(renewBefore).After(ca.Certificate().NotAfter) {
+		return nil
+	}
+	return ca
+}
+
+//stored parses the key pairs held by secret and checks they can still be used
+func (p *Provisioner) stored(secret *corev1.Secret) (*certs.KeyPair, *certs.KeyPair, bool) {
+	ca := storedCA(secret)
+	if ca == nil {
+		return nil, nil, false
+	}
+	serving, err := certs.ParseKeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, nil, false
+	}
+	if !serving.ValidFor(ca, p.DNSNames(), renewBefore) {
+		return nil, nil, false
+	}
+	return serving, ca, true
+}
+
+//EnsureWebhookConfiguration creates the ValidatingWebhookConfiguration or updates Aegir's webhook in it,
+//keeping any other webhook registered in the same configuration
+func (p *Provisioner) EnsureWebhookConfiguration(ctx context.Context, rl *rules.RulesList, caBundle []byte) error {
+	opts := p.Webhook
+	opts.CABundle = caBundle
+	desired := Configuration(rl, opts)
+
+	configs := p.Client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	current, err := configs.Get(ctx, opts.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := configs.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		log.Printf("Created ValidatingWebhookConfiguration %s", opts.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	webhook := desired.Webhooks[0]
+	replaced := false
+	for i := range current.Webhooks {
+		if current.Webhooks[i].Name == webhook.Name {
+			current.Webhooks[i] = webhook
+			replaced = true
+		}
+	}
+	if !replaced {
+		current.Webhooks = append(current.Webhooks, webhook)
+	}
+	if _, err := configs.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	log.Printf("Updated ValidatingWebhookConfiguration %s", opts.Name)
+	return nil
+}
@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/grupozap/aegir/internal/pkg/kinds"
+	"github.com/grupozap/aegir/internal/pkg/rules"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//AdmissionPath is the path Aegir serves admission reviews on
+const AdmissionPath = "/admission"
+
+//DefaultNamespaceLabel is the label the API server sets on every namespace with its own name (Kubernetes 1.21+)
+const DefaultNamespaceLabel = "kubernetes.io/metadata.name"
+
+//Options describes where the API server reaches Aegir's webhook
+type Options struct {
+	Name             string
+	ServiceName      string
+	ServiceNamespace string
+	CABundle         []byte
+	//NamespaceLabel is the namespace label holding its name, no namespaceSelector is set when empty
+	NamespaceLabel string
+	//SkippedNamespaces are left out of rules applied to every namespace, like SKIP_NAMESPACES
+	SkippedNamespaces []string
+}
+
+//WebhookName returns the name of Aegir's webhook inside the configuration
+func (o Options) WebhookName() string {
+	return fmt.Sprintf("%s.%s.svc", o.ServiceName, o.ServiceNamespace)
+}
+
+//Rules derives the webhook rules from the resource types and operations used by rl, grouped by API group and operations
+func Rules(rl *rules.RulesList) []admissionregistrationv1.RuleWithOperations {
+	//resources holds the operations of every resource of every group
+	resources := map[string]map[string]map[string]bool{}
+	for _, rule := range rl.Rules {
+		for _, name := range rule.Kinds() {
+			kind, ok := kinds.Lookup(name)
+			if !ok {
+				log.Printf("rule %s uses unknown resource_type %q, it is not routed by the webhook", rule.Name, name)
+				continue
+			}
+			for _, group := range kind.Groups {
+				if resources[group] == nil {
+					resources[group] = map[string]map[string]bool{}
+				}
+				if resources[group][kind.Resource] == nil {
+					resources[group][kind.Resource] = map[string]bool{}
+				}
+				for _, op := range rule.OperationTypes() {
+					resources[group][kind.Resource][op] = true
+				}
+			}
+		}
+	}
+
+	groups := make([]string, 0, len(resources))
+	for group := range resources {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	var webhookRules []admissionregistrationv1.RuleWithOperations
+	for _, group := range groups {
+		//Resources with the same operations share a webhook rule
+		var keys []string
+		byOperations := map[string][]string{}
+		operations := map[string][]admissionregistrationv1.OperationType{}
+		for r, ops := range resources[group] {
+			var types []admissionregistrationv1.OperationType
+			var names []string
+			for _, op := range rules.Operations {
+				if ops[op] {
+					types = append(types, admissionregistrationv1.OperationType(op))
+					names = append(names, op)
+				}
+			}
+			key := strings.Join(names, ",")
+			if _, ok := byOperations[key]; !ok {
+				keys = append(keys, key)
+				operations[key] = types
+			}
+			byOperations[key] = append(byOperations[key], r)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			rs := byOperations[key]
+			sort.Strings(rs)
+			webhookRules = append(webhookRules, admissionregistrationv1.RuleWithOperations{
+				Operations: operations[key],
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{group},
+					APIVersions: []string{"*"},
+					Resources:   rs,
+				},
+			})
+		}
+	}
+	return webhookRules
+}
+
+//FailurePolicy maps the failure_policy of rl to the failurePolicy of the webhook, used when Aegir can't be reached.
+//Requests are rejected with deny, otherwise they are allowed so an unavailable Aegir doesn't block the cluster.
+func FailurePolicy(rl *rules.RulesList) admissionregistrationv1.FailurePolicyType {
+	if rl.FailurePolicy == rules.FailurePolicyDeny {
+		return admissionregistrationv1.Fail
+	}
+	return admissionregistrationv1.Ignore
+}
+
+//NamespaceSelector derives which namespaces must be sent to the webhook.
+//Rules applied to "*" select every namespace but the skipped ones, otherwise only the namespaces named by the rules are selected.
+func NamespaceSelector(rl *rules.RulesList, label string, skipped []string) *metav1.LabelSelector {
+	if label == "" {
+		return nil
+	}
+	all := false
+	named := map[string]bool{}
+	for _, rule := range rl.Rules {
+		if rule.Namespace == "*" {
+			all = true
+		} else {
+			named[rule.Namespace] = true
+		}
+	}
+
+	var values []string
+	operator := metav1.LabelSelectorOpIn
+	if all {
+		operator = metav1.LabelSelectorOpNotIn
+		for _, ns := range skipped {
+			if ns != "" && !named[ns] {
+				values = append(values, ns)
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+	} else {
+		for ns := range named {
+			values = append(values, ns)
+		}
+	}
+	sort.Strings(values)
+	return &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: label, Operator: operator, Values: values},
+		},
+	}
+}
+
+//Webhook builds Aegir's webhook entry for the rules in rl
+func Webhook(rl *rules.RulesList, opts Options) admissionregistrationv1.ValidatingWebhook {
+	path := AdmissionPath
+	sideEffects := admissionregistrationv1.SideEffectClassNoneOnDryRun
+	failurePolicy := FailurePolicy(rl)
+	return admissionregistrationv1.ValidatingWebhook{
+		Name: opts.WebhookName(),
+		ClientConfig: admissionregistrationv1.WebhookClientConfig{
+			Service: &admissionregistrationv1.ServiceReference{
+				Name:      opts.ServiceName,
+				Namespace: opts.ServiceNamespace,
+				Path:      &path,
+			},
+			CABundle: opts.CABundle,
+		},
+		Rules:                   Rules(rl),
+		NamespaceSelector:       NamespaceSelector(rl, opts.NamespaceLabel, opts.SkippedNamespaces),
+		SideEffects:             &sideEffects,
+		FailurePolicy:           &failurePolicy,
+		AdmissionReviewVersions: []string{"v1beta1"},
+	}
+}
+
+//Configuration builds a ValidatingWebhookConfiguration routing every resource type used by rl to Aegir
+func Configuration(rl *rules.RulesList, opts Options) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionregistrationv1.SchemeGroupVersion.String(),
+			Kind:       "ValidatingWebhookConfiguration",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: opts.Name},
+		Webhooks:   []admissionregistrationv1.ValidatingWebhook{Webhook(rl, opts)},
+	}
+}
@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grupozap/aegir/internal/pkg/rules"
+	"gotest.tools/assert"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var testRules = &rules.RulesList{
+	Rules: []*rules.Rule{
+		{Name: "deployments", Namespace: "*", ResourceType: "Deployment"},
+		{Name: "pods", Namespace: "*", ResourceType: "Pod"},
+		{Name: "ingresses", Namespace: "platform", ResourceType: "Ingress"},
+		{Name: "typo", Namespace: "*", ResourceType: "Deploymnet"},
+	},
+}
+
+var testOptions = Options{
+	Name:             "aegir-webhook",
+	ServiceName:      "aegir",
+	ServiceNamespace: "platform",
+}
+
+func TestRules(t *testing.T) {
+	operations := []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}
+	expected := []admissionregistrationv1.RuleWithOperations{
+		{Operations: operations, Rule: admissionregistrationv1.Rule{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods"}}},
+		{Operations: operations, Rule: admissionregistrationv1.Rule{APIGroups: []string{"apps"}, APIVersions: []string{"*"}, Resources: []string{"deployments"}}},
+		{Operations: operations, Rule: admissionregistrationv1.Rule{APIGroups: []string{"extensions"}, APIVersions: []string{"*"}, Resources: []string{"deployments", "ingresses"}}},
+		{Operations: operations, Rule: admissionregistrationv1.Rule{APIGroups: []string{"networking.k8s.io"}, APIVersions: []string{"*"}, Resources: []string{"ingresses"}}},
+	}
+	assert.DeepEqual(t, Rules(testRules), expected)
+}
+
+func TestRulesPodSpec(t *testing.T) {
+	rl := &rules.RulesList{Rules: []*rules.Rule{{Name: "no_latest", Namespace: "*", ResourceType: rules.ResourceTypePodSpec}}}
+	resources := map[string][]string{}
+	for _, r := range Rules(rl) {
+		resources[r.APIGroups[0]] = r.Resources
+	}
+	assert.DeepEqual(t, resources[""], []string{"pods", "replicationcontrollers"})
+	assert.DeepEqual(t, resources["apps"], []string{"daemonsets", "deployments", "replicasets", "statefulsets"})
+	assert.DeepEqual(t, resources["batch"], []string{"cronjobs", "jobs"})
+}
+
+func TestRulesOperations(t *testing.T) {
+	rl := &rules.RulesList{Rules: []*rules.Rule{
+		{Name: "pods", Namespace: "*", ResourceType: "Pod"},
+		{Name: "protected_pods", Namespace: "*", ResourceType: "Pod", Operations: []string{"delete"}},
+		{Name: "services", Namespace: "*", ResourceType: "Service", Operations: []string{"CREATE"}},
+		{Name: "namespaces", Namespace: "*", ResourceType: "Namespace", Operations: []string{"*"}},
+	}}
+	expected := []admissionregistrationv1.RuleWithOperations{
+		{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+			Rule:       admissionregistrationv1.Rule{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"services"}},
+		},
+		{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete},
+			Rule:       admissionregistrationv1.Rule{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"pods"}},
+		},
+		{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete, admissionregistrationv1.Connect},
+			Rule:       admissionregistrationv1.Rule{APIGroups: []string{""}, APIVersions: []string{"*"}, Resources: []string{"namespaces"}},
+		},
+	}
+	assert.DeepEqual(t, Rules(rl), expected)
+}
+
+func TestWebhookFailurePolicy(t *testing.T) {
+	policies := map[string]admissionregistrationv1.FailurePolicyType{
+		"":                       admissionregistrationv1.Ignore,
+		rules.FailurePolicyAllow: admissionregistrationv1.Ignore,
+		rules.FailurePolicyDeny:  admissionregistrationv1.Fail,
+	}
+	for policy, expected := range policies {
+		rl := &rules.RulesList{FailurePolicy: policy, Rules: testRules.Rules}
+		assert.Equal(t, *Webhook(rl, testOptions).FailurePolicy, expected, policy)
+	}
+}
+
+func newProvisioner(objects ...interface{}) *Provisioner {
+	client := fake.NewSimpleClientset()
+	for _, o := range objects {
+		switch obj := o.(type) {
+		case *corev1.Secret:
+			client.CoreV1().Secrets(obj.Namespace).Create(context.Background(), obj, metav1.CreateOptions{})
+		case *admissionregistrationv1.ValidatingWebhookConfiguration:
+			client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Create(context.Background(), obj, metav1.CreateOptions{})
+		}
+	}
+	return &Provisioner{Client: client, SecretName: "aegir-tls", Webhook: testOptions}
+}
+
+func TestEnsureCertificateCreatesAndReusesSecret(t *testing.T) {
+	ctx := context.Background()
+	p := newProvisioner()
+	serving, ca, err := p.EnsureCertificate(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !serving.ValidFor(ca, p.DNSNames(), renewBefore) {
+		t.Error("expected the serving certificate to be valid for the service names")
+	}
+	secret, err := p.Client.CoreV1().Secrets("platform").Get(ctx, "aegir-tls", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the secret to be created: %v", err)
+	}
+	assert.DeepEqual(t, secret.Data[corev1.TLSCertKey], serving.CertPEM)
+
+	again, _, err := p.EnsureCertificate(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.DeepEqual(t, again.CertPEM, serving.CertPEM)
+}
+
+func TestEnsureCertificateReplacesInvalidSecret(t *testing.T) {
+	ctx := context.Background()
+	p := newProvisioner(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "aegir-tls", Namespace: "platform"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("garbage")},
+	})
+	serving, _, err := p.EnsureCertificate(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secret, _ := p.Client.CoreV1().Secrets("platform").Get(ctx, "aegir-tls", metav1.GetOptions{})
+	assert.DeepEqual(t, secret.Data[corev1.TLSCertKey], serving.CertPEM)
+}
+
+func TestEnsureCertificateRenewsWithStoredCA(t *testing.T) {
+	ctx := context.Background()
+	p := newProvisioner()
+	first, ca, err := p.EnsureCertificate(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secrets := p.Client.CoreV1().Secrets("platform")
+	secret, _ := secrets.Get(ctx, "aegir-tls", metav1.GetOptions{})
+	secret.Data[corev1.TLSCertKey] = []byte("expired")
+	secrets.Update(ctx, secret, metav1.UpdateOptions{})
+
+	serving, renewedCA, err := p.EnsureCertificate(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.DeepEqual(t, renewedCA.CertPEM, ca.CertPEM)
+	assert.Assert(t, string(serving.CertPEM) != string(first.CertPEM))
+	assert.Assert(t, serving.ValidFor(ca, p.DNSNames(), renewBefore))
+}
+
+func TestEnsureCertificateRetriesOnConflict(t *testing.T) {
+	ctx := context.Background()
+	p := newProvisioner(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "aegir-tls", Namespace: "platform"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("garbage")},
+	})
+	conflicts := 0
+	p.Client.(*fake.Clientset).PrependReactor("update", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			return false, nil, nil
+		}
+		conflicts++
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "secrets"}, "aegir-tls", nil)
+	})
+	serving, _, err := p.EnsureCertificate(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, conflicts, 1)
+	secret, _ := p.Client.CoreV1().Secrets("platform").Get(ctx, "aegir-tls", metav1.GetOptions{})
+	assert.DeepEqual(t, secret.Data[corev1.TLSCertKey], serving.CertPEM)
+}
+
+func TestEnsureWebhookConfigurationCreates(t *testing.T) {
+	ctx := context.Background()
+	p := newProvisioner()
+	if err := p.EnsureWebhookConfiguration(ctx, testRules, []byte("CA")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config, err := p.Client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "aegir-webhook", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the configuration to be created: %v", err)
+	}
+	assert.Equal(t, len(config.Webhooks), 1)
+	assert.Equal(t, config.Webhooks[0].Name, "aegir.platform.svc")
+	assert.DeepEqual(t, config.Webhooks[0].ClientConfig.CABundle, []byte("CA"))
+	assert.Equal(t, *config.Webhooks[0].ClientConfig.Service.Path, AdmissionPath)
+}
+
+func TestEnsureWebhookConfigurationPatchesOwnWebhook(t *testing.T) {
+	ctx := context.Background()
+	p := newProvisioner(&admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "aegir-webhook"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{Name: "other.webhook.svc"},
+			{Name: "aegir.platform.svc", ClientConfig: admissionregistrationv1.WebhookClientConfig{CABundle: []byte("OLD")}},
+		},
+	})
+	if err := p.EnsureWebhookConfiguration(ctx, testRules, []byte("NEW")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config, _ := p.Client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "aegir-webhook", metav1.GetOptions{})
+	assert.Equal(t, len(config.Webhooks), 2)
+	assert.Equal(t, config.Webhooks[0].Name, "other.webhook.svc")
+	assert.DeepEqual(t, config.Webhooks[1].ClientConfig.CABundle, []byte("NEW"))
+	assert.DeepEqual(t, config.Webhooks[1].Rules, Rules(testRules))
+}
+
+func TestNamespaceSelector(t *testing.T) {
+	if selector := NamespaceSelector(testRules, "", []string{"kube-system"}); selector != nil {
+		t.Errorf("expected no selector without a namespace label but got %v", selector)
+	}
+	if selector := NamespaceSelector(testRules, DefaultNamespaceLabel, []string{""}); selector != nil {
+		t.Errorf("expected no selector without skipped namespaces but got %v", selector)
+	}
+
+	expected := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: DefaultNamespaceLabel, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"istio-system", "kube-system"}},
+		},
+	}
+	//platform has its own rule, so it must still be sent to the webhook
+	assert.DeepEqual(t, NamespaceSelector(testRules, DefaultNamespaceLabel, []string{"kube-system", "platform", "istio-system"}), expected)
+
+	named := &rules.RulesList{Rules: []*rules.Rule{
+		{Namespace: "team-b", ResourceType: "Pod"},
+		{Namespace: "team-a", ResourceType: "Deployment"},
+	}}
+	expected = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: DefaultNamespaceLabel, Operator: metav1.LabelSelectorOpIn, Values: []string{"team-a", "team-b"}},
+		},
+	}
+	assert.DeepEqual(t, NamespaceSelector(named, DefaultNamespaceLabel, nil), expected)
+}
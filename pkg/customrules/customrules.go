@@ -0,0 +1,141 @@
+//Package customrules holds the LIVR rules Aegir adds to the standard ones, and lets programs
+//embedding Aegir register their own.
+package customrules
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	livr "github.com/k33nice/go-livr"
+)
+
+var (
+	mu       sync.RWMutex
+	once     sync.Once
+	registry = map[string]livr.Builder{}
+)
+
+//builtins is the catalogue of rules provided by Aegir
+var builtins = map[string]livr.Builder{
+	"neq":      neq,
+	"not_like": notLike,
+
+	"quantity_between": quantityBetween,
+	"quantity_min":     quantityMin,
+	"quantity_max":     quantityMax,
+	"duration_between": durationBetween,
+
+	"image_registry_in": imageRegistryIn,
+	"image_has_digest":  imageHasDigest,
+	"image_tag_not":     imageTagNot,
+	"image_semver":      imageSemver,
+
+	"less_than_field":           compareToField("less_than_field", func(cmp int) bool { return cmp < 0 }, "TOO_HIGH"),
+	"less_or_equal_to_field":    compareToField("less_or_equal_to_field", func(cmp int) bool { return cmp <= 0 }, "TOO_HIGH"),
+	"greater_than_field":        compareToField("greater_than_field", func(cmp int) bool { return cmp > 0 }, "TOO_LOW"),
+	"greater_or_equal_to_field": compareToField("greater_or_equal_to_field", func(cmp int) bool { return cmp >= 0 }, "TOO_LOW"),
+}
+
+//errorCodes are the error codes of the rules provided by Aegir
+var errorCodes = []string{
+	"FORMAT_ERROR",
+	"NOT_ALLOWED_VALUE",
+	"WRONG_FORMAT",
+	"WRONG_ARGUMENT",
+	"TOO_LOW",
+	"TOO_HIGH",
+	"REGISTRY_NOT_ALLOWED",
+	"DIGEST_REQUIRED",
+	"TAG_NOT_ALLOWED",
+	"NOT_SEMVER",
+	"VERSION_NOT_ALLOWED",
+}
+
+//ErrorCodes returns the error codes of the rules provided by Aegir
+func ErrorCodes() []string {
+	codes := append([]string(nil), errorCodes...)
+	sort.Strings(codes)
+	return codes
+}
+
+//ruleArgs returns the arguments of a rule, without the rule builders LIVR appends to them
+func ruleArgs(args ...interface{}) []interface{} {
+	if n := len(args); n > 0 {
+		if _, ok := args[n-1].(map[string]livr.Builder); ok {
+			return args[:n-1]
+		}
+	}
+	return args
+}
+
+//argumentError stops building a rule with invalid arguments. LIVR builds the rules of a validator when it is first used
+//and panics on unknown rules the same way, so both are reported when a rule definition is compiled.
+func argumentError(rule, format string, args ...interface{}) {
+	panic(fmt.Sprintf("%s: %s", rule, fmt.Sprintf(format, args...)))
+}
+
+//install makes rules known to LIVR, which only supports registering them globally
+func install(rules map[string]livr.Builder) {
+	livr.New(&livr.Options{}).RegisterDefaultRules(rules)
+}
+
+func registerBuiltins() {
+	once.Do(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for name, builder := range builtins {
+			registry[name] = builder
+		}
+		install(builtins)
+	})
+}
+
+//Register makes a rule available to every rule definition, replacing any rule with the same name.
+//It must be called before the rules are evaluated, usually before starting the server.
+func Register(name string, builder livr.Builder) error {
+	if name == "" {
+		return errors.New("a custom rule needs a name")
+	}
+	if builder == nil {
+		return errors.New("a custom rule needs a builder")
+	}
+	registerBuiltins()
+	mu.Lock()
+	defer mu.Unlock()
+	registry[name] = builder
+	install(map[string]livr.Builder{name: builder})
+	return nil
+}
+
+//Builtins returns the names of the rules provided by Aegir
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Names returns the names of every registered rule, built-in or not
+func Names() []string {
+	registerBuiltins()
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//NewValidator returns a LIVR validator of rules that knows the standard and the registered rules
+func NewValidator(rules livr.Dictionary) *livr.Validator {
+	registerBuiltins()
+	mu.RLock()
+	defer mu.RUnlock()
+	return livr.New(&livr.Options{LivrRules: rules})
+}
@@ -0,0 +1,121 @@
+package customrules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	livr "github.com/k33nice/go-livr"
+	"gotest.tools/assert"
+)
+
+type testCase struct {
+	rule  interface{}
+	value interface{}
+	err   string
+}
+
+//validate returns the error code of value validated by rule, or an empty string
+func validate(rule interface{}, data livr.Dictionary) string {
+	v := NewValidator(livr.Dictionary{"f": rule})
+	if _, err := v.Validate(data); err != nil {
+		if e, ok := v.Errors()["f"].(error); ok {
+			return e.Error()
+		}
+		return "unknown"
+	}
+	return ""
+}
+
+//run validates the cases of built-in rules, whose error codes must be listed by ErrorCodes
+func run(t *testing.T, cases []testCase) {
+	for _, c := range cases {
+		if c.err != "" && !contains(ErrorCodes(), c.err) {
+			t.Errorf("%v with %v: '%s' is not listed by ErrorCodes", c.rule, c.value, c.err)
+		}
+		if got := validate(c.rule, livr.Dictionary{"f": c.value}); got != c.err {
+			t.Errorf("%v with %v: expected '%s' but got '%s'", c.rule, c.value, c.err, got)
+		}
+	}
+}
+
+//buildError returns the error stopping rule from being built, or an empty string
+func buildError(rule interface{}) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprint(r)
+		}
+	}()
+	validate(rule, livr.Dictionary{})
+	return ""
+}
+
+func TestInvalidArguments(t *testing.T) {
+	cases := []struct {
+		rule interface{}
+		err  string
+	}{
+		{livr.Dictionary{"quantity_between": "1"}, "quantity_between: needs a minimum and a maximum, got [1]"},
+		{livr.Dictionary{"quantity_between": []interface{}{"1Gi", "lots"}}, `quantity_between: invalid bound lots: quantities must match the regular expression`},
+		{"quantity_min", "quantity_min: needs a limit, got []"},
+		{livr.Dictionary{"quantity_max": "a lot"}, "quantity_max: invalid bound a lot"},
+		{livr.Dictionary{"duration_between": "1h"}, "duration_between: needs a minimum and a maximum, got [1h]"},
+		{livr.Dictionary{"duration_between": []interface{}{"1h", "3 months"}}, "duration_between: invalid bound 3 months: time:"},
+		{"less_than_field", "less_than_field: needs the name of the field to compare with, got []"},
+		{"image_registry_in", "image_registry_in: needs at least one registry"},
+		{livr.Dictionary{"image_tag_not": []interface{}{}}, "image_tag_not: needs at least one tag"},
+		{livr.Dictionary{"image_semver": "not a constraint"}, `image_semver: invalid constraint "not a constraint"`},
+		{livr.Dictionary{"quantity_between": []interface{}{"100m", "2"}}, ""},
+		{"image_semver", ""},
+	}
+	for _, c := range cases {
+		got := buildError(c.rule)
+		if c.err == "" {
+			assert.Equal(t, got, "", "%v", c.rule)
+			continue
+		}
+		assert.Assert(t, got != "" && strings.HasPrefix(got, c.err), "%v: expected '%s' but got '%s'", c.rule, c.err, got)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	even := func(args ...interface{}) livr.Validation {
+		return func(value interface{}, builders ...interface{}) (interface{}, interface{}) {
+			if n, ok := value.(float64); ok && int(n)%2 != 0 {
+				return nil, errors.New("NOT_EVEN")
+			}
+			return value, nil
+		}
+	}
+	assert.NilError(t, Register("even", even))
+	assert.Assert(t, contains(Names(), "even"))
+	for _, c := range []testCase{
+		{"even", 2.0, ""},
+		{"even", 3.0, "NOT_EVEN"},
+		{livr.Dictionary{"list_of": "even"}, []interface{}{2.0, 4.0}, ""},
+	} {
+		if got := validate(c.rule, livr.Dictionary{"f": c.value}); got != c.err {
+			t.Errorf("%v with %v: expected '%s' but got '%s'", c.rule, c.value, c.err, got)
+		}
+	}
+
+	assert.Error(t, Register("", even), "a custom rule needs a name")
+	assert.Error(t, Register("odd", nil), "a custom rule needs a builder")
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNames(t *testing.T) {
+	names := Names()
+	for _, builtin := range Builtins() {
+		assert.Assert(t, contains(names, builtin), builtin)
+	}
+}
@@ -0,0 +1,104 @@
+package customrules
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/docker/distribution/reference"
+	"github.com/grupozap/aegir/internal/pkg/utils"
+	livr "github.com/k33nice/go-livr"
+)
+
+//imageRule builds rules validating container image references, like "nginx:1.19" or "gcr.io/project/app@sha256:..."
+func imageRule(check func(ref reference.Named) error) livr.Validation {
+	return func(value interface{}, builders ...interface{}) (interface{}, interface{}) {
+		if value == nil || value == "" {
+			return value, nil
+		}
+		image, ok := value.(string)
+		if !ok {
+			return nil, errors.New("FORMAT_ERROR")
+		}
+		ref, err := reference.ParseNormalizedNamed(image)
+		if err != nil {
+			return nil, errors.New("WRONG_FORMAT")
+		}
+		if err := check(ref); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+}
+
+//tag returns the tag of an image, images without tag nor digest are pulled as latest
+func tag(ref reference.Named) string {
+	if tagged, ok := ref.(reference.Tagged); ok {
+		return tagged.Tag()
+	}
+	if _, ok := ref.(reference.Digested); ok {
+		return ""
+	}
+	return "latest"
+}
+
+func imageRegistryIn(args ...interface{}) livr.Validation {
+	registries := stringArgs(args...)
+	if len(registries) == 0 {
+		argumentError("image_registry_in", "needs at least one registry")
+	}
+
+	return imageRule(func(ref reference.Named) error {
+		for _, r := range registries {
+			r = strings.TrimSuffix(r, "/")
+			if reference.Domain(ref) == r || strings.HasPrefix(ref.Name(), r+"/") {
+				return nil
+			}
+		}
+		return errors.New("REGISTRY_NOT_ALLOWED")
+	})
+}
+
+func imageHasDigest(args ...interface{}) livr.Validation {
+	return imageRule(func(ref reference.Named) error {
+		if _, ok := ref.(reference.Digested); !ok {
+			return errors.New("DIGEST_REQUIRED")
+		}
+		return nil
+	})
+}
+
+func imageTagNot(args ...interface{}) livr.Validation {
+	tags := stringArgs(args...)
+	if len(tags) == 0 {
+		argumentError("image_tag_not", "needs at least one tag")
+	}
+
+	return imageRule(func(ref reference.Named) error {
+		if utils.Include(tags, tag(ref)) {
+			return errors.New("TAG_NOT_ALLOWED")
+		}
+		return nil
+	})
+}
+
+func imageSemver(args ...interface{}) livr.Validation {
+	var constraint *semver.Constraints
+	if c := stringArgs(args...); len(c) > 0 {
+		var err error
+		if constraint, err = semver.NewConstraint(c[0]); err != nil {
+			argumentError("image_semver", "invalid constraint %q: %v", c[0], err)
+		}
+	}
+
+	return imageRule(func(ref reference.Named) error {
+		version, err := semver.NewVersion(tag(ref))
+		if err != nil {
+			return errors.New("NOT_SEMVER")
+		}
+		if constraint != nil && !constraint.Check(version) {
+			return errors.New("VERSION_NOT_ALLOWED")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,66 @@
+package customrules
+
+import (
+	"testing"
+
+	livr "github.com/k33nice/go-livr"
+)
+
+const digest = "@sha256:4b763a566cc3a8d8287ca4f7208923f0913c2cb8ff035dfc2846e8c11ad1dfc0"
+
+func TestImageRegistryIn(t *testing.T) {
+	rule := livr.Dictionary{"image_registry_in": []interface{}{"gcr.io/my-project", "docker.io"}}
+	run(t, []testCase{
+		{rule, "nginx", ""},
+		{rule, "gcr.io/my-project/app:1.0", ""},
+		{rule, "gcr.io/other/app:1.0", "REGISTRY_NOT_ALLOWED"},
+		{livr.Dictionary{"image_registry_in": "quay.io"}, "nginx:1.19", "REGISTRY_NOT_ALLOWED"},
+		{rule, "Not An Image", "WRONG_FORMAT"},
+		{rule, 1.0, "FORMAT_ERROR"},
+		{rule, "library/nginx:1.19", ""},
+		{rule, "gcr.io/my-project-2/app:1.0", "REGISTRY_NOT_ALLOWED"},
+		{rule, "", ""},
+		{rule, nil, ""},
+		{livr.Dictionary{"image_registry_in": "gcr.io/my-project/"}, "gcr.io/my-project/app:1.0", ""},
+		{livr.Dictionary{"image_registry_in": "gcr.io"}, "gcr.io/any/app:1.0", ""},
+		{livr.Dictionary{"image_registry_in": "docker.io"}, "registry:5000/app:1.0", "REGISTRY_NOT_ALLOWED"},
+	})
+}
+
+func TestImageHasDigest(t *testing.T) {
+	run(t, []testCase{
+		{"image_has_digest", "nginx" + digest, ""},
+		{"image_has_digest", "nginx:1.19" + digest, ""},
+		{"image_has_digest", "nginx:1.19", "DIGEST_REQUIRED"},
+		{"image_has_digest", "nginx", "DIGEST_REQUIRED"},
+		{"image_has_digest", "nginx@sha256:tooshort", "WRONG_FORMAT"},
+	})
+}
+
+func TestImageTagNot(t *testing.T) {
+	rule := livr.Dictionary{"image_tag_not": []interface{}{"latest", "master"}}
+	run(t, []testCase{
+		{rule, "nginx:1.19", ""},
+		{rule, "nginx", "TAG_NOT_ALLOWED"},
+		{rule, "app:master", "TAG_NOT_ALLOWED"},
+		{rule, "nginx" + digest, ""},
+		{rule, "nginx:latest" + digest, "TAG_NOT_ALLOWED"},
+		{rule, "registry:5000/app", "TAG_NOT_ALLOWED"},
+		{rule, "registry:5000/app:1.0", ""},
+		{livr.Dictionary{"image_tag_not": "latest"}, "nginx:Latest", ""},
+	})
+}
+
+func TestImageSemver(t *testing.T) {
+	run(t, []testCase{
+		{"image_semver", "nginx:1.19.2", ""},
+		{"image_semver", "nginx:latest", "NOT_SEMVER"},
+		{livr.Dictionary{"image_semver": ">= 1.20"}, "nginx:1.19.2", "VERSION_NOT_ALLOWED"},
+		{livr.Dictionary{"image_semver": ">= 1.20"}, "nginx:1.20.1", ""},
+		{"image_semver", "nginx:v1.19", ""},
+		{"image_semver", "nginx", "NOT_SEMVER"},
+		{"image_semver", "nginx" + digest, "NOT_SEMVER"},
+		{livr.Dictionary{"image_semver": "~1.19"}, "nginx:1.19.9", ""},
+		{livr.Dictionary{"image_semver": "~1.19"}, "nginx:1.20.0", "VERSION_NOT_ALLOWED"},
+	})
+}
@@ -0,0 +1,158 @@
+package customrules
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/grupozap/aegir/internal/pkg/utils"
+	livr "github.com/k33nice/go-livr"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+//quantity parses numbers and strings like "500m" or "1Gi" as Kubernetes quantities
+func quantity(value interface{}) (resource.Quantity, error) {
+	switch v := value.(type) {
+	case float64:
+		return resource.ParseQuantity(strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		return resource.ParseQuantity(v)
+	default:
+		return resource.Quantity{}, errors.New("not a quantity")
+	}
+}
+
+//compareToField builds rules comparing a value with another field of the same object,
+//like equal_to_field. Values are compared as Kubernetes quantities, so "500m" is less than 1.
+//The rule passes when the value is missing, required should be used to enforce it. It fails with WRONG_ARGUMENT
+//when the other field is missing or is not a quantity.
+func compareToField(name string, pass func(cmp int) bool, errorCode string) livr.Builder {
+	return func(args ...interface{}) livr.Validation {
+		args = ruleArgs(args...)
+		field, _ := utils.FirstArg(args...).(string)
+		if field == "" {
+			argumentError(name, "needs the name of the field to compare with, got %v", args)
+		}
+
+		return func(value interface{}, builders ...interface{}) (interface{}, interface{}) {
+			if value == nil || value == "" {
+				return value, nil
+			}
+			var params livr.Dictionary
+			if len(builders) > 0 {
+				params, _ = builders[0].(livr.Dictionary)
+			}
+
+			v, err := quantity(value)
+			if err != nil {
+				return nil, errors.New("FORMAT_ERROR")
+			}
+			o, err := quantity(params[field])
+			if err != nil {
+				return nil, errors.New("WRONG_ARGUMENT")
+			}
+			if !pass(v.Cmp(o)) {
+				return nil, errors.New(errorCode)
+			}
+			return value, nil
+		}
+	}
+}
+
+//between builds the range check of rule from parse, used by the quantity and duration rules.
+//A nil bound is not checked, bounds that can't be parsed stop building the rule.
+func between(rule string, min, max interface{}, parse func(interface{}) (interface{}, error), cmp func(a, b interface{}) int) livr.Validation {
+	bound := func(b interface{}) interface{} {
+		if b == nil {
+			return nil
+		}
+		parsed, err := parse(b)
+		if err != nil {
+			argumentError(rule, "invalid bound %v: %v", b, err)
+		}
+		return parsed
+	}
+	lower, upper := bound(min), bound(max)
+
+	return func(value interface{}, builders ...interface{}) (interface{}, interface{}) {
+		if value == nil || value == "" {
+			return value, nil
+		}
+		v, err := parse(value)
+		if err != nil {
+			return nil, errors.New("FORMAT_ERROR")
+		}
+		if lower != nil && cmp(v, lower) < 0 {
+			return nil, errors.New("TOO_LOW")
+		}
+		if upper != nil && cmp(v, upper) > 0 {
+			return nil, errors.New("TOO_HIGH")
+		}
+		return value, nil
+	}
+}
+
+func parseQuantity(value interface{}) (interface{}, error) {
+	return quantity(value)
+}
+
+func compareQuantities(a, b interface{}) int {
+	qa, qb := a.(resource.Quantity), b.(resource.Quantity)
+	return qa.Cmp(qb)
+}
+
+func parseDuration(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("not a duration")
+	}
+	return time.ParseDuration(s)
+}
+
+func compareDurations(a, b interface{}) int {
+	da, db := a.(time.Duration), b.(time.Duration)
+	switch {
+	case da < db:
+		return -1
+	case da > db:
+		return 1
+	}
+	return 0
+}
+
+//bounds returns the first two arguments of rule, like [min, max], both are required
+func bounds(rule string, args ...interface{}) (interface{}, interface{}) {
+	args = ruleArgs(args...)
+	if len(args) < 2 || args[0] == nil || args[1] == nil {
+		argumentError(rule, "needs a minimum and a maximum, got %v", args)
+	}
+	return args[0], args[1]
+}
+
+//limit returns the only argument of rule, which is required
+func limit(rule string, args ...interface{}) interface{} {
+	args = ruleArgs(args...)
+	l := utils.FirstArg(args...)
+	if l == nil {
+		argumentError(rule, "needs a limit, got %v", args)
+	}
+	return l
+}
+
+func quantityBetween(args ...interface{}) livr.Validation {
+	min, max := bounds("quantity_between", args...)
+	return between("quantity_between", min, max, parseQuantity, compareQuantities)
+}
+
+func quantityMin(args ...interface{}) livr.Validation {
+	return between("quantity_min", limit("quantity_min", args...), nil, parseQuantity, compareQuantities)
+}
+
+func quantityMax(args ...interface{}) livr.Validation {
+	return between("quantity_max", nil, limit("quantity_max", args...), parseQuantity, compareQuantities)
+}
+
+func durationBetween(args ...interface{}) livr.Validation {
+	min, max := bounds("duration_between", args...)
+	return between("duration_between", min, max, parseDuration, compareDurations)
+}
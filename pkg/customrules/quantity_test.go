@@ -0,0 +1,85 @@
+package customrules
+
+import (
+	"testing"
+
+	livr "github.com/k33nice/go-livr"
+)
+
+func TestQuantityBetween(t *testing.T) {
+	rule := livr.Dictionary{"quantity_between": []interface{}{"100m", 2.0}}
+	run(t, []testCase{
+		{rule, "500m", ""},
+		{rule, 1.0, ""},
+		{rule, "50m", "TOO_LOW"},
+		{rule, "2.5", "TOO_HIGH"},
+		{rule, "lots", "FORMAT_ERROR"},
+		{rule, nil, ""},
+		{livr.Dictionary{"quantity_between": []interface{}{"256Mi", "1Gi"}}, "1Gi", ""},
+		{livr.Dictionary{"quantity_between": []interface{}{"256Mi", "1Gi"}}, "1025Mi", "TOO_HIGH"},
+		{rule, "", ""},
+		{rule, true, "FORMAT_ERROR"},
+	})
+}
+
+func TestQuantityMinMax(t *testing.T) {
+	run(t, []testCase{
+		{livr.Dictionary{"quantity_min": "128Mi"}, "1Gi", ""},
+		{livr.Dictionary{"quantity_min": "128Mi"}, "64Mi", "TOO_LOW"},
+		{livr.Dictionary{"quantity_max": "1Gi"}, "1024Mi", ""},
+		{livr.Dictionary{"quantity_max": "1Gi"}, "2Gi", "TOO_HIGH"},
+		{livr.Dictionary{"quantity_min": 2.0}, "1500m", "TOO_LOW"},
+		{livr.Dictionary{"quantity_min": "100m"}, 1.0, ""},
+		{livr.Dictionary{"quantity_max": "1Gi"}, "1G", ""},
+		{livr.Dictionary{"quantity_max": "1G"}, "1Gi", "TOO_HIGH"},
+		{livr.Dictionary{"quantity_max": "1Gi"}, "lots", "FORMAT_ERROR"},
+	})
+}
+
+func TestDurationBetween(t *testing.T) {
+	rule := livr.Dictionary{"duration_between": []interface{}{"1h", "2160h"}}
+	run(t, []testCase{
+		{rule, "720h", ""},
+		{rule, "30m", "TOO_LOW"},
+		{rule, "2161h", "TOO_HIGH"},
+		{rule, 3600.0, "FORMAT_ERROR"},
+		{rule, "1h", ""},
+		{rule, "90m", ""},
+		{rule, "a day", "FORMAT_ERROR"},
+		{rule, nil, ""},
+	})
+}
+
+func TestCompareToField(t *testing.T) {
+	cases := []struct {
+		rule             string
+		requests, limits interface{}
+		err              string
+	}{
+		{"less_or_equal_to_field", "500m", 1.0, ""},
+		{"less_or_equal_to_field", "1Gi", "1024Mi", ""},
+		{"less_or_equal_to_field", "2", "1", "TOO_HIGH"},
+		{"less_than_field", "1Gi", "1024Mi", "TOO_HIGH"},
+		{"less_than_field", "900Mi", "1Gi", ""},
+		{"less_than_field", "1Gi", "900Mi", "TOO_HIGH"},
+		{"less_or_equal_to_field", "900Mi", "1Gi", ""},
+		{"less_or_equal_to_field", "1Gi", "900Mi", "TOO_HIGH"},
+		{"greater_than_field", "1Gi", "900Mi", ""},
+		{"greater_than_field", "900Mi", "1Gi", "TOO_LOW"},
+		{"greater_or_equal_to_field", "1Gi", "1024Mi", ""},
+		{"greater_or_equal_to_field", "900Mi", "1Gi", "TOO_LOW"},
+		{"greater_than_field", "2", "1", ""},
+		{"greater_or_equal_to_field", "500m", "1", "TOO_LOW"},
+		{"less_or_equal_to_field", "2", nil, "WRONG_ARGUMENT"},
+		{"greater_than_field", "2", "", "WRONG_ARGUMENT"},
+		{"less_or_equal_to_field", "2", "lots", "WRONG_ARGUMENT"},
+		{"less_or_equal_to_field", nil, "1", ""},
+		{"less_or_equal_to_field", "lots", "1", "FORMAT_ERROR"},
+	}
+	for _, c := range cases {
+		got := validate(livr.Dictionary{c.rule: "limits"}, livr.Dictionary{"f": c.requests, "limits": c.limits})
+		if got != c.err {
+			t.Errorf("%s with %v and %v: expected '%s' but got '%s'", c.rule, c.requests, c.limits, c.err, got)
+		}
+	}
+}
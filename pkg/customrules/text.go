@@ -1,4 +1,4 @@
-package main
+package customrules
 
 import (
 	"errors"
@@ -35,7 +35,7 @@ func neq(args ...interface{}) livr.Validation {
 	}
 }
 
-func not_like(args ...interface{}) livr.Validation {
+func notLike(args ...interface{}) livr.Validation {
 	var re *regexp.Regexp
 	var flags string
 	if len(args) > 0 {
@@ -79,14 +79,16 @@ func not_like(args ...interface{}) livr.Validation {
 	}
 }
 
-var customRules map[string]livr.Builder
-
-func init() {
-	customRules = map[string]livr.Builder{
-		"neq":      neq,
-		"not_like": not_like,
+//stringArgs returns the string arguments of a rule, accepting both [a, b] and [[a, b]]
+func stringArgs(args ...interface{}) []string {
+	var strs []string
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			strs = append(strs, v)
+		case []interface{}:
+			strs = append(strs, stringArgs(v...)...)
+		}
 	}
-
-	v := livr.New(&livr.Options{})
-	v.RegisterDefaultRules(customRules)
+	return strs
 }
@@ -0,0 +1,27 @@
+package customrules
+
+import (
+	"testing"
+
+	livr "github.com/k33nice/go-livr"
+)
+
+func TestNeq(t *testing.T) {
+	run(t, []testCase{
+		{livr.Dictionary{"neq": "latest"}, "1.0", ""},
+		{livr.Dictionary{"neq": "latest"}, "latest", "NOT_ALLOWED_VALUE"},
+		{livr.Dictionary{"neq": 0.0}, 0.0, "NOT_ALLOWED_VALUE"},
+		{livr.Dictionary{"neq": "latest"}, []interface{}{"latest"}, "FORMAT_ERROR"},
+		{livr.Dictionary{"neq": "latest"}, nil, ""},
+	})
+}
+
+func TestNotLike(t *testing.T) {
+	run(t, []testCase{
+		{livr.Dictionary{"not_like": ":latest$"}, "nginx:1.19", ""},
+		{livr.Dictionary{"not_like": ":latest$"}, "nginx:latest", "WRONG_FORMAT"},
+		{livr.Dictionary{"not_like": []interface{}{"^ROOT$", "i"}}, "root", "WRONG_FORMAT"},
+		{livr.Dictionary{"not_like": "^0$"}, 0.0, "WRONG_FORMAT"},
+		{livr.Dictionary{"not_like": "x"}, true, "FORMAT_ERROR"},
+	})
+}